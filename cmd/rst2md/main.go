@@ -1,26 +1,284 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
 	"github.com/spandigital/presidium-rst-to-markdown/pkg/processor"
 )
 
+// Exit codes
+const (
+	exitOK         = 0
+	exitConversion = 1 // The conversion or check could not be run
+	exitUsage      = 2 // Invalid command or flags
+	exitValidation = 3 // The generated site has problems
+)
+
+// Build information, set by the release build through -ldflags
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"convert", "Convert a Sphinx/RST project into a Presidium site (default)", runConvert},
+		{"check", "Check a generated site for broken links, images and menu entries", runCheck},
+		{"init", "Write a starter " + config.FileName + " with the given flags to the input directory", runInit},
+		{"watch", "Convert, then convert again whenever the input changes", runWatch},
+		{"version", "Print version and build information", runVersion},
+		{"help", "Show help for a command", runHelp},
+	}
+}
+
 func main() {
-	cfg := config.ParseArgs()
+	os.Exit(run(os.Args[1:]))
+}
 
-	if cfg.Verbose {
+// run dispatches to the command named by the first argument. Without a
+// command name the arguments are passed to convert, so that plain flags keep
+// working as before subcommands existed.
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "-h", "-help", "--help":
+		usage(os.Stdout)
+		return exitOK
+	case "-version", "--version":
+		return runVersion(nil)
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return runConvert(args)
+	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		return cmd.run(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "rst2md: unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: rst2md <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s  %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'rst2md help <command>' for the flags of a command.")
+}
+
+// parseError returns the exit code for an error from parsing flags, which
+// has already been reported along with the usage.
+func parseError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// runError reports err and returns the matching exit code.
+func runError(err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if errors.Is(err, processor.ErrValidationFailed) {
+		return exitValidation
+	}
+	return exitConversion
+}
+
+func setupLogging(verbose bool) {
+	if verbose {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 	} else {
 		log.SetFlags(0)
 		log.SetOutput(io.Discard)
 	}
+}
+
+// logConfig logs the effective configuration and any problems loading it in
+// verbose mode.
+func logConfig(cfg config.Config) {
+	if cfg.Verbose {
+		log.Printf("Effective configuration:\n%s", cfg.Dump())
+	}
+	for _, warning := range cfg.Warnings {
+		log.Printf("Warning: %s", warning)
+	}
+}
+
+func runConvert(args []string) int {
+	cfg, err := config.ParseArgs(args, os.Stderr)
+	if err != nil {
+		return parseError(err)
+	}
+	setupLogging(cfg.Verbose)
+	logConfig(cfg)
 
 	if err := processor.Run(cfg); err != nil {
-		log.Fatalf("Error: %v", err)
+		return runError(err)
 	}
 
 	log.Println("Conversion completed successfully.")
+	return exitOK
+}
+
+func runCheck(args []string) int {
+	cfg, err := config.ParseCheckArgs(args, os.Stderr)
+	if err != nil {
+		return parseError(err)
+	}
+	setupLogging(cfg.Verbose)
+
+	if err := processor.Validate(cfg.OutputDir, os.Stdout); err != nil {
+		return runError(err)
+	}
+	fmt.Println("No problems found.")
+	return exitOK
+}
+
+func runInit(args []string) int {
+	var cfg config.Config
+	fs := config.NewFlagSet("init", &cfg, os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if cfg.InputDir == "" {
+		cfg.InputDir = "."
+	}
+
+	path := cfg.ConfigFile
+	if path == "" {
+		path = filepath.Join(cfg.InputDir, config.FileName)
+	}
+	if _, err := os.Stat(path); err == nil && !cfg.Force {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists; use -force to overwrite it\n", path)
+		return exitConversion
+	}
+
+	// Paths in the file are relative to the directory holding it
+	saved := cfg
+	saved.InputDir = relativePath(filepath.Dir(path), cfg.InputDir)
+	saved.OutputDir = relativePath(filepath.Dir(path), cfg.OutputDir)
+	saved.Force = false
+	if err := saved.Write(path); err != nil {
+		return runError(err)
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return exitOK
+}
+
+// relativePath returns path relative to dir if both can be made absolute.
+func relativePath(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	absDir, err1 := filepath.Abs(dir)
+	absPath, err2 := filepath.Abs(path)
+	if err1 != nil || err2 != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(absDir, absPath); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func runWatch(args []string) int {
+	var cfg config.Config
+	fs := config.NewFlagSet("watch", &cfg, os.Stderr)
+	interval := fs.Duration("interval", time.Second, "How often to poll the input directory for changes")
+	if err := config.Load(fs, &cfg, args); err != nil {
+		return parseError(err)
+	}
+	if err := cfg.RequireDirs(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		return parseError(err)
+	}
+	setupLogging(cfg.Verbose)
+	logConfig(cfg)
+
+	// Every run after the first replaces the previous output
+	cfg.Force = true
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Watching %s, press Ctrl+C to stop\n", cfg.InputDir)
+	err := processor.Watch(ctx, cfg, *interval, func(err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Converted %s at %s\n", cfg.InputDir, time.Now().Format(time.TimeOnly))
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return runError(err)
+	}
+	return exitOK
+}
+
+func runVersion([]string) int {
+	v, rev := version, commit
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" {
+		if info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && rev == "none" {
+				rev = s.Value
+			}
+		}
+	}
+	fmt.Printf("rst2md %s (commit %s, built %s, %s %s/%s)\n", v, rev, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return exitOK
+}
+
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return exitOK
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil || cmd.name == "help" {
+		fmt.Fprintf(os.Stderr, "rst2md: unknown command %q\n", args[0])
+		return exitUsage
+	}
+	fmt.Printf("%s: %s\n\n", cmd.name, cmd.summary)
+	return cmd.run([]string{"-h"})
 }
@@ -2,4 +2,7 @@ module github.com/spandigital/presidium-rst-to-markdown
 
 go 1.22
 
-require gopkg.in/yaml.v2 v2.4.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -1,8 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -10,33 +18,186 @@ const (
 	FilePermission = 0644
 )
 
+// FileName is the name of the project configuration file.
+const FileName = "rst2md.yaml"
+
+// ErrMissingDirs is returned when a command is run without the directories
+// it needs.
+var ErrMissingDirs = errors.New("both -input and -output are required")
+
 type Config struct {
-	InputDir    string
-	OutputDir   string
-	PandocPath  string
-	Force       bool
-	Verbose     bool
-	MaxParallel int
-	Depth       int // Maximum heading depth to split sections
+	InputDir    string `yaml:"input" toml:"input"`
+	OutputDir   string `yaml:"output" toml:"output"`
+	PandocPath  string `yaml:"pandoc-path" toml:"pandoc-path"`
+	Converter   string `yaml:"converter" toml:"converter"` // Converter backend used to turn RST into Markdown
+	Force       bool   `yaml:"force" toml:"force"`
+	Verbose     bool   `yaml:"verbose" toml:"verbose"`
+	MaxParallel int    `yaml:"parallel" toml:"parallel"`
+	Depth       int    `yaml:"depth" toml:"depth"`           // Maximum heading depth to split sections
+	Split       string `yaml:"split" toml:"split"`           // Split strategy: none, heading, size or marker
+	SplitSize   int    `yaml:"split-size" toml:"split-size"` // Size in bytes above which the size strategy splits
+	Validate    bool   `yaml:"validate" toml:"validate"`     // Check links, images and menu entries after conversion
+	ConfigFile  string `yaml:"-" toml:"-"`                   // Configuration file the settings were loaded from
+
+	// Admonition rendering: callout shortcodes, gfm alerts or html as converted
+	Admonitions string `yaml:"admonitions" toml:"admonitions"`
+	// Callout type of each admonition, overriding the defaults
+	AdmonitionTypes map[string]string `yaml:"admonition-types,omitempty" toml:"admonition-types,omitempty"`
+	// Code block rendering: fenced code with Hugo attributes or the highlight shortcode
+	CodeBlocks string `yaml:"code-blocks" toml:"code-blocks"`
+	// Image placement: shared as laid out in the input or in page bundles
+	Images string `yaml:"images" toml:"images"`
+	// Rendering of tables that cannot be pipe tables: html or a table shortcode
+	Tables string `yaml:"tables" toml:"tables"`
+	// Math rendering: $ delimiters or a math shortcode
+	Math string `yaml:"math" toml:"math"`
+	// Glossary placement: a glossary page of its own or inline where defined
+	Glossary string `yaml:"glossary" toml:"glossary"`
+	// Front matter format: yaml, toml or json
+	FrontMatter string `yaml:"front-matter" toml:"front-matter"`
+	// Front matter key of each document field, an empty key dropping the field
+	FrontMatterFields map[string]string `yaml:"front-matter-fields,omitempty" toml:"front-matter-fields,omitempty"`
+	// Front matter params added to the pages of the documents matching a glob
+	FrontMatterRules []FrontMatterRule `yaml:"front-matter-rules,omitempty" toml:"front-matter-rules,omitempty"`
+	// Split strategies of the documents matching a glob
+	SplitRules []SplitRule `yaml:"split-rules,omitempty" toml:"split-rules,omitempty"`
+
+	// Project settings, read from the Sphinx conf.py unless set here
+	Project         string   `yaml:"project,omitempty" toml:"project,omitempty"`
+	Author          string   `yaml:"author,omitempty" toml:"author,omitempty"`
+	Version         string   `yaml:"version,omitempty" toml:"version,omitempty"`
+	MasterDoc       string   `yaml:"master-doc,omitempty" toml:"master-doc,omitempty"`             // Root document, index by default
+	SourceSuffix    []string `yaml:"source-suffix,omitempty" toml:"source-suffix,omitempty"`       // Suffixes of RST sources, .rst by default
+	ExcludePatterns []string `yaml:"exclude-patterns,omitempty" toml:"exclude-patterns,omitempty"` // Glob patterns of sources to skip
+	RstProlog       string   `yaml:"rst-prolog,omitempty" toml:"rst-prolog,omitempty"`
+	RstEpilog       string   `yaml:"rst-epilog,omitempty" toml:"rst-epilog,omitempty"`
+	StaticPaths     []string `yaml:"static-paths,omitempty" toml:"static-paths,omitempty"` // Directories copied to _static
+	// Default language of code and literal blocks
+	HighlightLanguage string `yaml:"highlight-language,omitempty" toml:"highlight-language,omitempty"`
+
+	Warnings []string `yaml:"-" toml:"-"` // Problems found while loading the configuration
 }
 
-// ParseArgs parses command-line arguments and returns a Config struct.
-func ParseArgs() Config {
+// FrontMatterRule adds Params to the front matter of every page of the
+// documents whose names match the glob Path, in which * and ? do not match
+// slashes while ** matches anything. String params are Go templates.
+type FrontMatterRule struct {
+	Path   string                 `yaml:"path" toml:"path"`
+	Params map[string]interface{} `yaml:"params" toml:"params"`
+}
+
+// SplitRule sets the split strategy of the documents whose names match the
+// glob Path, and optionally their depth and size.
+type SplitRule struct {
+	Path  string `yaml:"path" toml:"path"`
+	Split string `yaml:"split" toml:"split"`
+	Depth int    `yaml:"depth,omitempty" toml:"depth,omitempty"`
+	Size  int    `yaml:"split-size,omitempty" toml:"split-size,omitempty"`
+}
+
+// NewFlagSet returns a flag set for the named command with the conversion
+// flags bound to cfg. Errors are returned by Parse rather than exiting, and
+// usage is written to output.
+func NewFlagSet(name string, cfg *Config, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cfg.InputDir, "input", "", "Input directory")
+	fs.StringVar(&cfg.OutputDir, "output", "", "Output directory")
+	fs.StringVar(&cfg.PandocPath, "pandoc-path", "pandoc", "Path to the Pandoc executable")
+	fs.StringVar(&cfg.Converter, "converter", "pandoc", "Converter backend used to turn RST into Markdown: pandoc or native")
+	fs.BoolVar(&cfg.Force, "force", false, "Force overwrite of output directory")
+	fs.BoolVar(&cfg.Verbose, "v", false, "Enable verbose logging")
+	fs.IntVar(&cfg.MaxParallel, "parallel", 4, "Maximum number of parallel processes")
+	fs.IntVar(&cfg.Depth, "depth", 2, "Heading depth level to split sections")
+	fs.StringVar(&cfg.Split, "split", "heading", "Split strategy: none (a page per document), heading (a page per heading up to -depth), size (headings only above -split-size) or marker (at rst2md-split directives)")
+	fs.IntVar(&cfg.SplitSize, "split-size", 20000, "Size in bytes of Markdown above which the size strategy splits a document")
+	fs.StringVar(&cfg.Admonitions, "admonitions", "callout", "Admonition style: callout (Presidium shortcodes), gfm (alerts) or html")
+	fs.StringVar(&cfg.CodeBlocks, "code-blocks", "fence", "Code block style: fence (attributes such as {linenos=table}) or shortcode (Hugo highlight)")
+	fs.StringVar(&cfg.Images, "images", "shared", "Image placement: shared (as laid out in the input directory) or bundle (beside the pages using them)")
+	fs.StringVar(&cfg.Tables, "tables", "html", "Style of tables that cannot be pipe tables: html or shortcode (Hugo table shortcode around HTML)")
+	fs.StringVar(&cfg.Math, "math", "dollars", "Math style: dollars ($...$ and $$...$$ for KaTeX or MathJax) or shortcode (Hugo math shortcode)")
+	fs.StringVar(&cfg.Glossary, "glossary", "page", "Glossary placement: page (every term on a glossary page) or inline (where the terms are defined)")
+	fs.StringVar(&cfg.FrontMatter, "front-matter", "yaml", "Front matter format: yaml (between --- lines), toml (between +++ lines) or json")
+	fs.BoolVar(&cfg.Validate, "validate", false, "Check links, images and menu entries of the generated site")
+	fs.StringVar(&cfg.ConfigFile, "config", "", "Configuration file (default: "+FileName+" or rst2md.toml in the input directory)")
+	return fs
+}
+
+// ParseArgs parses the arguments of the convert command and returns a Config
+// struct. The error and usage are written to output on error.
+func ParseArgs(args []string, output io.Writer) (Config, error) {
 	var config Config
-	flag.StringVar(&config.InputDir, "input", "", "Input directory")
-	flag.StringVar(&config.OutputDir, "output", "", "Output directory")
-	flag.StringVar(&config.PandocPath, "pandoc-path", "pandoc", "Path to the Pandoc executable")
-	flag.BoolVar(&config.Force, "force", false, "Force overwrite of output directory")
-	flag.BoolVar(&config.Verbose, "v", false, "Enable verbose logging")
-	flag.IntVar(&config.MaxParallel, "parallel", 4, "Maximum number of parallel processes")
-	flag.IntVar(&config.Depth, "depth", 2, "Heading depth level to split sections")
+	fs := NewFlagSet("convert", &config, output)
+	if err := Load(fs, &config, args); err != nil {
+		return config, err
+	}
+	if err := config.RequireDirs(); err != nil {
+		fmt.Fprintln(output, err)
+		fs.Usage()
+		return config, err
+	}
+	return config, nil
+}
 
-	flag.Parse()
+// RequireDirs returns ErrMissingDirs unless both the input and output
+// directories are set.
+func (c Config) RequireDirs() error {
+	if c.InputDir == "" || c.OutputDir == "" {
+		return ErrMissingDirs
+	}
+	return nil
+}
 
-	if config.InputDir == "" || config.OutputDir == "" {
-		flag.Usage()
-		os.Exit(1)
+// ParseCheckArgs parses the arguments of the check command, which takes the
+// output directory to validate as a flag, as its only argument or from the
+// configuration file. The argument stands for the flag, so that both
+// override the file.
+func ParseCheckArgs(args []string, output io.Writer) (Config, error) {
+	var config Config
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&config.OutputDir, "output", "", "Output directory to check")
+	fs.BoolVar(&config.Verbose, "v", false, "Enable verbose logging")
+	fs.StringVar(&config.ConfigFile, "config", "", "Configuration file (default: "+FileName+" or rst2md.toml in the current directory)")
+
+	if err := fs.Parse(args); err != nil {
+		return config, err
+	}
+	if config.OutputDir == "" && fs.NArg() == 1 {
+		if err := fs.Set("output", fs.Arg(0)); err != nil {
+			return config, err
+		}
 	}
+	if err := merge(fs, &config); err != nil {
+		fmt.Fprintln(output, err)
+		return config, err
+	}
+	if config.OutputDir == "" {
+		err := errors.New("an output directory is required")
+		fmt.Fprintln(output, err)
+		fs.Usage()
+		return config, err
+	}
+	return config, nil
+}
 
-	return config
+// Write saves the configuration to path, as TOML if it has a .toml
+// extension and as YAML otherwise.
+func (c Config) Write(path string) error {
+	var data []byte
+	var err error
+	if filepath.Ext(path) == ".toml" {
+		var b bytes.Buffer
+		err = toml.NewEncoder(&b).Encode(c)
+		data = b.Bytes()
+	} else {
+		data, err = yaml.Marshal(c)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+	if err := os.WriteFile(path, data, FilePermission); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
 }
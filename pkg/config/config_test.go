@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    Config
+		wantErr error
+	}{
+		{
+			name: "defaults",
+			args: []string{"-input", "docs", "-output", "site"},
+			want: Config{InputDir: "docs", OutputDir: "site", PandocPath: "pandoc", Converter: "pandoc", MaxParallel: 4, Depth: 2, Split: "heading", SplitSize: 20000, Admonitions: "callout", CodeBlocks: "fence", Images: "shared", Tables: "html", Math: "dollars", Glossary: "page", FrontMatter: "yaml"},
+		},
+		{
+			name: "flags",
+			args: []string{"-input", "docs", "-output", "site", "-converter", "native", "-depth", "3", "-validate", "-force"},
+			want: Config{InputDir: "docs", OutputDir: "site", PandocPath: "pandoc", Converter: "native", Force: true, MaxParallel: 4, Depth: 3, Split: "heading", SplitSize: 20000, Admonitions: "callout", CodeBlocks: "fence", Images: "shared", Tables: "html", Math: "dollars", Glossary: "page", FrontMatter: "yaml", Validate: true},
+		},
+		{
+			name:    "missing output",
+			args:    []string{"-input", "docs"},
+			wantErr: ErrMissingDirs,
+		},
+		{
+			name:    "help",
+			args:    []string{"-h"},
+			wantErr: flag.ErrHelp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseArgs(tt.args, io.Discard)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseArgs() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArgs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := "output: site\nconverter: native\ndepth: 3\nvalidate: true\n"
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(file), FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RST2MD_DEPTH", "4")
+
+	cfg, err := ParseArgs([]string{"-input", dir, "-converter", "pandoc"}, io.Discard)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	want := Config{
+		InputDir:    dir,
+		OutputDir:   filepath.Join(dir, "site"), // Relative to the file
+		PandocPath:  "pandoc",
+		Converter:   "pandoc", // Flags override the file
+		MaxParallel: 4,
+		Depth:       4, // The environment overrides both
+		Split:       "heading",
+		SplitSize:   20000,
+		Admonitions: "callout",
+		CodeBlocks:  "fence",
+		Images:      "shared",
+		Tables:      "html",
+		Math:        "dollars",
+		Glossary:    "page",
+		FrontMatter: "yaml",
+		Validate:    true,
+		ConfigFile:  filepath.Join(dir, FileName),
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ParseArgs() = %+v, want %+v", cfg, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("unknown: 1\n"), FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseArgs([]string{"-input", dir, "-output", "site"}, io.Discard); err == nil {
+		t.Error("ParseArgs() accepted an unknown setting")
+	}
+}
+
+func TestParseCheckArgs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, FileName)
+	if err := os.WriteFile(file, []byte("output: site\n"), FilePermission); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "argument",
+			args: []string{"public"},
+			want: "public",
+		},
+		{
+			name: "flag",
+			args: []string{"-output", "public"},
+			want: "public",
+		},
+		{
+			name: "file",
+			args: []string{"-config", file},
+			want: filepath.Join(dir, "site"),
+		},
+		{
+			name: "argument overrides the file",
+			args: []string{"-config", file, "public"},
+			want: "public",
+		},
+		{
+			name: "flag overrides the file",
+			args: []string{"-config", file, "-output", "public"},
+			want: "public",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseCheckArgs(tt.args, io.Discard)
+			if err != nil {
+				t.Fatalf("ParseCheckArgs() error = %v", err)
+			}
+			if cfg.OutputDir != tt.want {
+				t.Errorf("ParseCheckArgs() output = %q, want %q", cfg.OutputDir, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseCheckArgs(nil, io.Discard); err == nil {
+		t.Error("ParseCheckArgs() accepted no output directory")
+	}
+}
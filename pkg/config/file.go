@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/sphinx"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix prefixes the environment variables overriding settings, such as
+// RST2MD_OUTPUT or RST2MD_PANDOC_PATH.
+const EnvPrefix = "RST2MD_"
+
+// fileNames are the configuration files looked for in the input directory.
+var fileNames = []string{FileName, "rst2md.yml", "rst2md.toml"}
+
+// envNames maps flags to environment variable suffixes where they differ from
+// the flag name.
+var envNames = map[string]string{
+	"v": "VERBOSE",
+}
+
+// Load parses args with fs, whose flags are bound to cfg, and merges them with
+// the configuration file, the environment and the Sphinx conf.py. Settings from the file are
+// overridden by flags, which are overridden by RST2MD_* environment
+// variables. The file is the -config flag or RST2MD_CONFIG if set, or else
+// the first of rst2md.yaml, rst2md.yml and rst2md.toml found in the input
+// directory, or the current directory if no input directory is given.
+// Project settings still unset are then read from conf.py. Errors are written to the output of fs, like flag parsing errors.
+func Load(fs *flag.FlagSet, cfg *Config, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := merge(fs, cfg); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return err
+	}
+	return nil
+}
+
+// merge applies the configuration file, the environment and conf.py to the
+// parsed flags of fs.
+func merge(fs *flag.FlagSet, cfg *Config) error {
+	// Remember the flags given on the command line
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	path, err := findFile(cfg)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		if err := readFile(path, cfg); err != nil {
+			return err
+		}
+		cfg.ConfigFile = path
+		for name, value := range set {
+			if err := fs.Set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || envErr != nil {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				envErr = fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+			}
+		}
+	})
+	if envErr != nil {
+		return envErr
+	}
+	return applySphinxConf(cfg)
+}
+
+// applySphinxConf fills the project settings not set otherwise from the
+// conf.py in the input directory, if there is one.
+func applySphinxConf(cfg *Config) error {
+	if cfg.InputDir == "" {
+		return nil
+	}
+	path := filepath.Join(cfg.InputDir, sphinx.ConfFile)
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	conf, err := sphinx.ReadConf(path)
+	if err != nil {
+		return err
+	}
+
+	setString := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	setList := func(field *[]string, value []string) {
+		if len(*field) == 0 {
+			*field = value
+		}
+	}
+	setString(&cfg.Project, conf.Project)
+	setString(&cfg.Author, conf.Author)
+	setString(&cfg.Version, conf.Version)
+	setString(&cfg.MasterDoc, conf.MasterDoc)
+	setList(&cfg.SourceSuffix, conf.SourceSuffix)
+	setList(&cfg.ExcludePatterns, conf.ExcludePatterns)
+	setString(&cfg.RstProlog, conf.RstProlog)
+	setString(&cfg.RstEpilog, conf.RstEpilog)
+	setList(&cfg.StaticPaths, conf.HTMLStaticPath)
+	setString(&cfg.HighlightLanguage, conf.HighlightLanguage)
+	for _, name := range conf.Unsupported {
+		cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("%s: %s is not a literal and was ignored", path, name))
+	}
+	return nil
+}
+
+// envName returns the environment variable overriding the named flag.
+func envName(flagName string) string {
+	if name, ok := envNames[flagName]; ok {
+		return EnvPrefix + name
+	}
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// findFile returns the configuration file to load, or an empty string if
+// there is none.
+func findFile(cfg *Config) (string, error) {
+	path := cfg.ConfigFile
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("configuration file not found: %w", err)
+		}
+		return path, nil
+	}
+
+	dir := cfg.InputDir
+	if env := os.Getenv(envName("input")); env != "" {
+		dir = env
+	}
+	if dir == "" {
+		dir = "."
+	}
+	for _, name := range fileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// readFile loads the YAML or TOML configuration file at path into cfg.
+// Settings missing from the file are left as they are, and relative input and
+// output directories are resolved against the directory of the file.
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	inputDir, outputDir := cfg.InputDir, cfg.OutputDir
+	cfg.InputDir, cfg.OutputDir = "", ""
+	if filepath.Ext(path) == ".toml" {
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown setting %s in %s", undecoded[0], path)
+		}
+	} else if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	cfg.InputDir = resolvePath(dir, cfg.InputDir, inputDir)
+	cfg.OutputDir = resolvePath(dir, cfg.OutputDir, outputDir)
+	return nil
+}
+
+// resolvePath returns value resolved against dir, or fallback if it is empty.
+func resolvePath(dir, value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	if filepath.IsAbs(value) {
+		return value
+	}
+	return filepath.Join(dir, value)
+}
+
+// Dump returns the configuration as YAML, noting the file it was loaded from
+// and the RST2MD_* variables set in the environment.
+func (c Config) Dump() string {
+	var b bytes.Buffer
+	if c.ConfigFile != "" {
+		fmt.Fprintf(&b, "# Loaded from %s\n", c.ConfigFile)
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	b.Write(data)
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, EnvPrefix) {
+			env = append(env, kv)
+		}
+	}
+	sort.Strings(env)
+	for _, kv := range env {
+		fmt.Fprintf(&b, "# %s\n", kv)
+	}
+	return b.String()
+}
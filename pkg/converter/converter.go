@@ -3,28 +3,65 @@ package converter
 import (
 	"context"
 	"fmt"
-	"os/exec"
-	"time"
+	"io"
+	"os"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
 )
 
-// ConvertRSTToMarkdown converts an RST file to Markdown using Pandoc.
-func ConvertRSTToMarkdown(inputPath, outputPath, pandocPath string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
+// Names of the built-in converter backends, selectable via config.Config.Converter.
+const (
+	BackendPandoc = "pandoc"
+	BackendNative = "native"
+)
 
-	cmd := exec.CommandContext(ctx, pandocPath, "-f", "rst", "-t", "gfm", inputPath, "-o", outputPath)
-	stderr, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error converting %s: %v\n%s", inputPath, err, stderr)
+// Converter turns reStructuredText read from r into GitHub-flavoured Markdown written to w.
+type Converter interface {
+	Convert(ctx context.Context, r io.Reader, w io.Writer) error
+}
+
+// Checker is implemented by converters that depend on external tooling and can
+// verify that it is available before any conversion is attempted.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// ConverterFunc adapts an ordinary function to the Converter interface.
+type ConverterFunc func(ctx context.Context, r io.Reader, w io.Writer) error
+
+// Convert calls f(ctx, r, w).
+func (f ConverterFunc) Convert(ctx context.Context, r io.Reader, w io.Writer) error {
+	return f(ctx, r, w)
+}
+
+// New returns the converter backend selected in the configuration.
+func New(cfg config.Config) (Converter, error) {
+	switch cfg.Converter {
+	case "", BackendPandoc:
+		return NewPandoc(cfg.PandocPath), nil
+	case BackendNative:
+		return NewNative(), nil
+	default:
+		return nil, fmt.Errorf("unknown converter backend %q", cfg.Converter)
 	}
-	return nil
 }
 
-// CheckPandoc verifies if Pandoc is available in the system.
-func CheckPandoc(pandocPath string) error {
-	cmd := exec.Command(pandocPath, "--version")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("pandoc not found: %w", err)
+// ConvertFile converts the RST file at inputPath and writes the Markdown to outputPath.
+func ConvertFile(ctx context.Context, c Converter, inputPath, outputPath string) error {
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("error converting %s: %w", inputPath, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("error converting %s: %w", inputPath, err)
+	}
+	defer out.Close()
+
+	if err := c.Convert(ctx, in, out); err != nil {
+		return fmt.Errorf("error converting %s: %w", inputPath, err)
 	}
 	return nil
 }
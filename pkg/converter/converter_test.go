@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		wantErr bool
+	}{
+		{
+			name:    "default",
+			backend: "",
+		},
+		{
+			name:    "pandoc",
+			backend: BackendPandoc,
+		},
+		{
+			name:    "native",
+			backend: BackendNative,
+		},
+		{
+			name:    "unknown",
+			backend: "asciidoctor",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.Config{Converter: tt.backend, PandocPath: "pandoc"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.rst")
+	outputPath := filepath.Join(dir, "out.md")
+	if err := os.WriteFile(inputPath, []byte("hello"), config.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+
+	upper := ConverterFunc(func(ctx context.Context, r io.Reader, w io.Writer) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, strings.ToUpper(string(data)))
+		return err
+	})
+
+	if err := ConvertFile(context.Background(), upper, inputPath, outputPath); err != nil {
+		t.Fatalf("ConvertFile() error = %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "HELLO" {
+		t.Errorf("ConvertFile() wrote %q, want %q", got, "HELLO")
+	}
+}
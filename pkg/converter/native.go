@@ -0,0 +1,29 @@
+package converter
+
+import (
+	"context"
+	"io"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+)
+
+// Native converts RST to Markdown with the built-in parser, without any
+// external dependencies.
+type Native struct{}
+
+// NewNative returns a Native converter.
+func NewNative() *Native {
+	return &Native{}
+}
+
+// Convert parses the RST read from r and writes it to w as Markdown.
+func (n *Native) Convert(ctx context.Context, r io.Reader, w io.Writer) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return rst.Render(w, rst.Parse(string(src)))
+}
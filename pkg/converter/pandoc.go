@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// Pandoc converts RST to Markdown by shelling out to the pandoc executable.
+type Pandoc struct {
+	Path    string        // Path to the pandoc executable
+	Timeout time.Duration // Maximum duration of a single conversion
+}
+
+// NewPandoc returns a Pandoc converter using the executable at path.
+func NewPandoc(path string) *Pandoc {
+	return &Pandoc{
+		Path:    path,
+		Timeout: time.Minute,
+	}
+}
+
+// Convert pipes r through `pandoc -f rst -t gfm` and writes the result to w.
+func (p *Pandoc) Convert(ctx context.Context, r io.Reader, w io.Writer) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path, "-f", "rst", "-t", "gfm")
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pandoc: %v\n%s", err, stderr.Bytes())
+	}
+	return nil
+}
+
+// Check verifies that the pandoc executable is available.
+func (p *Pandoc) Check(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, p.Path, "--version")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pandoc not found: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Admonition styles.
+const (
+	AdmonitionCallout = "callout" // Presidium callout shortcodes
+	AdmonitionGFM     = "gfm"     // GitHub alerts such as > [!NOTE]
+	AdmonitionHTML    = "html"    // The div the converter produced
+)
+
+// DefaultAdmonitionTypes maps the Sphinx and docutils admonitions to callout
+// types. The types double as GitHub alert kinds where they are one.
+var DefaultAdmonitionTypes = map[string]string{
+	"admonition": "note",
+	"attention":  "important",
+	"caution":    "caution",
+	"danger":     "caution",
+	"error":      "caution",
+	"hint":       "tip",
+	"important":  "important",
+	"note":       "note",
+	"seealso":    "note",
+	"tip":        "tip",
+	"todo":       "note",
+	"warning":    "warning",
+}
+
+// admonitionTitles are the default titles of the admonitions whose names are
+// not their title capitalized.
+var admonitionTitles = map[string]string{"seealso": "See also"}
+
+// gfmAlerts are the alert kinds GitHub renders.
+var gfmAlerts = map[string]bool{"note": true, "tip": true, "important": true, "warning": true, "caution": true}
+
+var (
+	divOpenRegex  = regexp.MustCompile(`^<div class="([^"]*)">\s*$`)
+	divCloseRegex = regexp.MustCompile(`^</div>\s*$`)
+	fenceRegex    = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+// Admonitions rewrites the admonition divs both converters produce, a div
+// classed with the admonition name holding an optional title div, in the
+// given style.
+type Admonitions struct {
+	Style string
+	Types map[string]string // Callout type keyed by admonition name
+}
+
+// NewAdmonitions returns the admonition rewriter for style, with types
+// overriding DefaultAdmonitionTypes.
+func NewAdmonitions(style string, types map[string]string) (*Admonitions, error) {
+	switch style {
+	case "", AdmonitionCallout:
+		style = AdmonitionCallout
+	case AdmonitionGFM, AdmonitionHTML:
+	default:
+		return nil, fmt.Errorf("unknown admonition style %q: want callout, gfm or html", style)
+	}
+	a := &Admonitions{Style: style, Types: map[string]string{}}
+	for name, t := range DefaultAdmonitionTypes {
+		a.Types[name] = t
+	}
+	for name, t := range types {
+		a.Types[strings.ToLower(name)] = t
+	}
+	return a, nil
+}
+
+// Rewrite returns md with its admonitions rewritten. Nested admonitions are
+// rewritten too, and other divs and code blocks are left alone.
+func (a *Admonitions) Rewrite(md string) string {
+	if a == nil || a.Style == AdmonitionHTML {
+		return md
+	}
+	lines := strings.Split(md, "\n")
+	var out []string
+	inFence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			if inFence == "" {
+				inFence = m[1]
+			} else if m[1] == inFence {
+				inFence = ""
+			}
+		}
+		name, ok := a.admonitionName(line)
+		if inFence != "" || !ok {
+			out = append(out, line)
+			continue
+		}
+		end := closingDiv(lines, i)
+		if end < 0 {
+			out = append(out, line)
+			continue
+		}
+		title, body := splitTitle(lines[i+1 : end])
+		out = append(out, a.render(name, title, a.Rewrite(strings.Join(body, "\n"))))
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// admonitionName returns the admonition a div opening line starts.
+func (a *Admonitions) admonitionName(line string) (string, bool) {
+	m := divOpenRegex.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	for _, class := range strings.Fields(m[1]) {
+		if _, ok := a.Types[class]; ok {
+			return class, true
+		}
+	}
+	return "", false
+}
+
+// closingDiv returns the index of the line closing the div opened at start,
+// or -1 if it is not closed.
+func closingDiv(lines []string, start int) int {
+	depth := 0
+	inFence := ""
+	for i := start; i < len(lines); i++ {
+		if m := fenceRegex.FindStringSubmatch(lines[i]); m != nil {
+			if inFence == "" {
+				inFence = m[1]
+			} else if m[1] == inFence {
+				inFence = ""
+			}
+			continue
+		}
+		switch {
+		case inFence != "":
+		case divOpenRegex.MatchString(lines[i]):
+			depth++
+		case divCloseRegex.MatchString(lines[i]):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTitle separates the title div at the start of an admonition from its
+// body.
+func splitTitle(lines []string) (string, []string) {
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start == len(lines) || strings.TrimSpace(lines[start]) != `<div class="title">` {
+		return "", lines
+	}
+	end := closingDiv(lines, start)
+	if end < 0 {
+		return "", lines
+	}
+	title := strings.Join(strings.Fields(strings.Join(lines[start+1:end], " ")), " ")
+	return title, lines[end+1:]
+}
+
+// render writes an admonition in the configured style.
+func (a *Admonitions) render(name, title, body string) string {
+	kind := a.Types[name]
+	if title == "" {
+		title = admonitionTitles[name]
+	}
+	if title == "" {
+		title = strings.ToUpper(name[:1]) + name[1:]
+	}
+	body = strings.Trim(body, "\n")
+
+	if a.Style == AdmonitionGFM {
+		if !gfmAlerts[kind] {
+			kind = "note"
+		}
+		quoted := []string{"> [!" + strings.ToUpper(kind) + "]"}
+		// Alerts have no title of their own, so a custom one leads the body
+		if !strings.EqualFold(title, kind) {
+			quoted = append(quoted, "> **"+title+"**", ">")
+		}
+		for _, line := range strings.Split(body, "\n") {
+			quoted = append(quoted, strings.TrimRight("> "+line, " "))
+		}
+		return strings.Join(quoted, "\n")
+	}
+	return fmt.Sprintf("{{%% callout type=%q title=%q %%}}\n%s\n{{%% /callout %%}}", kind, title, body)
+}
@@ -0,0 +1,79 @@
+package processor
+
+import "testing"
+
+func TestAdmonitionsRewrite(t *testing.T) {
+	note := "Intro.\n\n<div class=\"note\">\n\n<div class=\"title\">\n\nNote\n\n</div>\n\nBe *careful*.\n\nReally.\n\n</div>\n\nAfter."
+	custom := "<div class=\"admonition\">\n\n<div class=\"title\">\n\nRead this\n\n</div>\n\nOuter.\n\n" +
+		"<div class=\"danger\">\n\n<div class=\"title\">\n\nDanger\n\n</div>\n\nInner.\n\n</div>\n\n</div>"
+	fenced := "```html\n<div class=\"note\">\n</div>\n```"
+
+	tests := []struct {
+		name  string
+		style string
+		types map[string]string
+		md    string
+		want  string
+	}{
+		{
+			name:  "callout",
+			style: AdmonitionCallout,
+			md:    note,
+			want:  "Intro.\n\n{{% callout type=\"note\" title=\"Note\" %}}\nBe *careful*.\n\nReally.\n{{% /callout %}}\n\nAfter.",
+		},
+		{
+			name:  "nested callouts with a custom title and type",
+			style: AdmonitionCallout,
+			types: map[string]string{"danger": "warning"},
+			md:    custom,
+			want: "{{% callout type=\"note\" title=\"Read this\" %}}\nOuter.\n\n" +
+				"{{% callout type=\"warning\" title=\"Danger\" %}}\nInner.\n{{% /callout %}}\n{{% /callout %}}",
+		},
+		{
+			name:  "default title",
+			style: AdmonitionCallout,
+			md:    "<div class=\"seealso\">\n\nOther pages.\n\n</div>",
+			want:  "{{% callout type=\"note\" title=\"See also\" %}}\nOther pages.\n{{% /callout %}}",
+		},
+		{
+			name:  "gfm",
+			style: AdmonitionGFM,
+			md:    note,
+			want:  "Intro.\n\n> [!NOTE]\n> Be *careful*.\n>\n> Really.\n\nAfter.",
+		},
+		{
+			name:  "gfm with a custom title",
+			style: AdmonitionGFM,
+			md:    "<div class=\"danger\">\n\n<div class=\"title\">\n\nDanger\n\n</div>\n\nHot.\n\n</div>",
+			want:  "> [!CAUTION]\n> **Danger**\n>\n> Hot.",
+		},
+		{
+			name:  "html",
+			style: AdmonitionHTML,
+			md:    note,
+			want:  note,
+		},
+		{
+			name:  "code blocks are left alone",
+			style: AdmonitionCallout,
+			md:    fenced,
+			want:  fenced,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAdmonitions(tt.style, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Rewrite(tt.md); got != tt.want {
+				t.Errorf("Rewrite() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewAdmonitions("bogus", nil); err == nil {
+		t.Error("NewAdmonitions() accepted an unknown style")
+	}
+}
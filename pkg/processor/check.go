@@ -0,0 +1,255 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Kinds of problems reported by Check.
+const (
+	ProblemBrokenLink   = "broken link"
+	ProblemBrokenAnchor = "broken anchor"
+	ProblemMissingImage = "missing image"
+	ProblemEmptyMenu    = "menu entry without content"
+)
+
+// ErrValidationFailed is returned when the generated site has problems.
+var ErrValidationFailed = errors.New("validation failed")
+
+var (
+	markdownLinkRegex = regexp.MustCompile(`(!?)\[(?:[^\]\\]|\\.)*\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+	htmlImageRegex    = regexp.MustCompile(`<img\s[^>]*\bsrc="([^"]+)"`)
+	htmlLinkRegex     = regexp.MustCompile(`<a\s[^>]*\bhref="([^"]+)"`)
+	figureSrcRegex    = regexp.MustCompile(`\{\{<\s*figure\s[^>]*\bsrc="([^"]+)"`)
+	codeSpanRegex     = regexp.MustCompile("`+[^`]*`+")
+)
+
+// Check scans the site generated in outputDir for internal links, anchors
+// and images that do not resolve, and for menu entries in config.yaml whose
+// URLs have no content. Problems are sorted by page.
+func Check(outputDir string) ([]types.Problem, error) {
+	pages, err := readSitePages(outputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated pages: %w", err)
+	}
+	byURL := map[string]*types.Page{}
+	for _, page := range pages {
+		byURL[page.URL] = page
+	}
+
+	var problems []types.Problem
+	for _, page := range pages {
+		content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(page.Path)))
+		if err != nil {
+			return nil, err
+		}
+		_, _, body := splitFrontMatter(string(content))
+		for _, ref := range pageReferences(body) {
+			if kind := checkReference(outputDir, byURL, page, ref); kind != "" {
+				problems = append(problems, types.Problem{Kind: kind, Page: page.Path, Target: ref.target})
+			}
+		}
+	}
+
+	menuProblems, err := checkMenu(outputDir, byURL)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, menuProblems...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Page < problems[j].Page
+	})
+	return problems, nil
+}
+
+// WriteReport writes a human-readable report of problems to w.
+func WriteReport(w io.Writer, problems []types.Problem) {
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s: %s %s\n", p.Page, p.Kind, p.Target)
+	}
+	fmt.Fprintf(w, "%d problem(s) found\n", len(problems))
+}
+
+// readSitePages reads every Markdown page below outputDir.
+func readSitePages(outputDir string) ([]*types.Page, error) {
+	var pages []*types.Page
+	err := filepath.Walk(outputDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(filePath) != ".md" {
+			return nil
+		}
+		relPath, err := filepath.Rel(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+		page, err := readPage(outputDir, filepath.ToSlash(relPath))
+		if err != nil {
+			return err
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	return pages, err
+}
+
+// reference is a link or image target found in a page.
+type reference struct {
+	target string
+	image  bool
+}
+
+// pageReferences returns the Markdown and HTML links and images of a page
+// body, ignoring code.
+func pageReferences(body string) []reference {
+	var refs []reference
+	fenced := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		line = codeSpanRegex.ReplaceAllString(line, "")
+		for _, m := range markdownLinkRegex.FindAllStringSubmatch(line, -1) {
+			refs = append(refs, reference{target: m[2], image: m[1] == "!"})
+		}
+		for _, m := range htmlImageRegex.FindAllStringSubmatch(line, -1) {
+			refs = append(refs, reference{target: m[1], image: true})
+		}
+		for _, m := range figureSrcRegex.FindAllStringSubmatch(line, -1) {
+			refs = append(refs, reference{target: m[1], image: true})
+		}
+		for _, m := range htmlLinkRegex.FindAllStringSubmatch(line, -1) {
+			refs = append(refs, reference{target: m[1]})
+		}
+	}
+	return refs
+}
+
+// checkReference returns the kind of problem with a reference from page, or
+// an empty string if it resolves.
+func checkReference(outputDir string, byURL map[string]*types.Page, page *types.Page, ref reference) string {
+	target := ref.target
+	if isExternalTarget(target) {
+		return ""
+	}
+	target, fragment, _ := strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	broken := ProblemBrokenLink
+	if ref.image {
+		broken = ProblemMissingImage
+	}
+	if target == "" {
+		if hasAnchor(page, fragment) {
+			return ""
+		}
+		return ProblemBrokenAnchor
+	}
+
+	// Files, such as images, resolve against the URL of the page, as in
+	// the browser
+	var candidates []string
+	if strings.HasPrefix(target, "/") {
+		candidates = append(candidates, target, path.Join("/static", target))
+	} else {
+		candidates = append(candidates, path.Join(page.URL, target))
+	}
+	for _, candidate := range candidates {
+		info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(candidate)))
+		if err == nil && !info.IsDir() {
+			return ""
+		}
+	}
+	if ref.image {
+		return broken
+	}
+
+	// Pages resolve against the URL of the page
+	linkURL := target
+	if !strings.HasPrefix(target, "/") {
+		linkURL = path.Join(page.URL, target)
+	}
+	if !strings.HasSuffix(linkURL, "/") {
+		linkURL += "/"
+	}
+	linked, ok := byURL[linkURL]
+	if !ok {
+		return broken
+	}
+	if fragment != "" && !hasAnchor(linked, fragment) {
+		return ProblemBrokenAnchor
+	}
+	return ""
+}
+
+// hasAnchor reports whether page has the anchor, counting its title.
+func hasAnchor(page *types.Page, anchor string) bool {
+	if utils.HeadingAnchor(page.Title) == anchor {
+		return true
+	}
+	for _, a := range page.Anchors {
+		if a == anchor {
+			return true
+		}
+	}
+	return false
+}
+
+// isExternalTarget reports whether a link target points outside the site or
+// is generated by Hugo.
+func isExternalTarget(target string) bool {
+	return strings.Contains(target, "://") || strings.HasPrefix(target, "//") ||
+		strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "tel:") ||
+		strings.Contains(target, "{{")
+}
+
+// checkMenu reports menu entries in config.yaml whose URLs have no content.
+func checkMenu(outputDir string, byURL map[string]*types.Page) ([]types.Problem, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, "config.yaml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var siteConfig types.SiteConfig
+	if err := yaml.Unmarshal(data, &siteConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse config.yaml: %w", err)
+	}
+
+	var problems []types.Problem
+	for _, item := range siteConfig.Menu.Main {
+		if item.URL == "" || isExternalTarget(item.URL) {
+			continue
+		}
+		menuURL := item.URL
+		if !strings.HasSuffix(menuURL, "/") {
+			menuURL += "/"
+		}
+		if _, ok := byURL[menuURL]; !ok {
+			problems = append(problems, types.Problem{Kind: ProblemEmptyMenu, Page: "config.yaml", Target: item.URL})
+		}
+	}
+	return problems, nil
+}
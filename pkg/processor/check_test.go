@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"intro/_index.md": "---\ntitle: Intro\n---\n\n" +
+			"[ok](/guide/usage/#flags) [rel](../guide/) [self](#setup) [gone](#nowhere)\n" +
+			"![logo](logo.png) ![lost](lost.png) <img src=\"/images/a.png\">\n" +
+			"[missing](/guide/missing/) [ext](https://example.com) `[code](nope.md)`\n\n" +
+			"## Setup\n\n```\n[fenced](nope.md)\n```\n",
+		"intro/logo.png":      "png",
+		"static/images/a.png": "png",
+		"guide/_index.md":     "---\ntitle: Guide\n---\n\n[anchor](usage/#nope)\n",
+		"guide/usage.md":      "---\ntitle: Usage\n---\n\n## Flags\n\n![up](../shot.png) {{< figure src=\"shot.png\" >}}\n",
+		"guide/shot.png":      "png",
+		"config.yaml":         "menu:\n  main:\n  - identifier: intro\n    url: /intro/\n  - identifier: docs\n  - identifier: old\n    url: /old/\n",
+	}
+	writeFiles(t, dir, files)
+
+	problems, err := Check(dir)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	want := []types.Problem{
+		{Kind: ProblemEmptyMenu, Page: "config.yaml", Target: "/old/"},
+		{Kind: ProblemBrokenAnchor, Page: "guide/_index.md", Target: "usage/#nope"},
+		{Kind: ProblemMissingImage, Page: "guide/usage.md", Target: "shot.png"},
+		{Kind: ProblemBrokenAnchor, Page: "intro/_index.md", Target: "#nowhere"},
+		{Kind: ProblemMissingImage, Page: "intro/_index.md", Target: "lost.png"},
+		{Kind: ProblemBrokenLink, Page: "intro/_index.md", Target: "/guide/missing/"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("Check() = %v, want %v", problems, want)
+	}
+}
@@ -0,0 +1,319 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// Code block styles.
+const (
+	CodeBlockFence     = "fence"     // Fenced code with Hugo attributes such as {linenos=table}
+	CodeBlockShortcode = "shortcode" // The Hugo highlight shortcode
+)
+
+var (
+	codeDirectiveRegex = regexp.MustCompile(`^(\s*)\.\.\s+(code-block|sourcecode|code|highlight)::(?:\s+(.*?))?\s*$`)
+	listMarkerRegex    = regexp.MustCompile(`^(?:[-*+•‣⁃]|\(?(?:\d+|#|[a-zA-Z])[.)])\s+`)
+	markdownEscapeReg  = regexp.MustCompile("([\\\\`*_\\[\\]<])")
+	backtickFenceRegex = regexp.MustCompile("`{3,}")
+)
+
+// CodeBlocks rewrites code-block, sourcecode and code directives, and literal
+// blocks once a default language is set, into Markdown code blocks keeping
+// their language, caption, name, line numbers and emphasized lines. Both
+// converters drop those options, so the blocks are rendered before
+// conversion and passed through as raw blocks.
+type CodeBlocks struct {
+	Style    string
+	Language string // Default language, such as conf.py's highlight_language
+}
+
+// NewCodeBlocks returns the code block rewriter for style, with language as
+// the default language of every document.
+func NewCodeBlocks(style, language string) (*CodeBlocks, error) {
+	switch style {
+	case "", CodeBlockFence:
+		style = CodeBlockFence
+	case CodeBlockShortcode:
+	default:
+		return nil, fmt.Errorf("unknown code block style %q: want fence or shortcode", style)
+	}
+	return &CodeBlocks{Style: style, Language: highlightLanguage(language)}, nil
+}
+
+// highlightLanguage maps a Sphinx highlight language to a Hugo one. The
+// Sphinx default and none leave code blocks without a language.
+func highlightLanguage(language string) string {
+	switch language = strings.TrimSpace(language); language {
+	case "default", "none":
+		return ""
+	case "python3", "py3":
+		return "python"
+	}
+	return language
+}
+
+// codeListing is a code block and the options rst2md carries through.
+type codeListing struct {
+	Language    string
+	Caption     string
+	Name        string
+	LineNos     bool
+	LineNoStart int
+	Highlight   []string // Emphasized lines and line ranges such as 3-5
+	Lines       []string
+}
+
+// Rewrite returns the RST src with its code blocks replaced by raw blocks
+// holding their Markdown. A highlight directive sets the default language for
+// the rest of the document and is removed.
+func (c *CodeBlocks) Rewrite(src string) string {
+	if c == nil {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+	language := c.Language
+
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if literal[i] {
+			out = append(out, line)
+			continue
+		}
+
+		if m := codeDirectiveRegex.FindStringSubmatch(line); m != nil {
+			indent := len(m[1])
+			end := blockEnd(lines, i, indent)
+			d := parseDirectiveBlock(lines[i:end], indent)
+			i = end - 1
+			if d == nil {
+				continue
+			}
+			if m[2] == "highlight" {
+				language = highlightLanguage(d.Argument)
+				continue
+			}
+			out = append(out, rawMarkdown(m[1], c.render(directiveListing(d, language)))...)
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		// Literal blocks follow a paragraph ending in ::, but not a directive
+		// or a title underlined with colons
+		if language == "" || !strings.HasSuffix(trimmed, "::") || strings.HasPrefix(trimmed, "..") ||
+			(trimmed != "::" && strings.Trim(trimmed, ":") == "") {
+			out = append(out, line)
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && literal[end] {
+			end++
+		}
+		if end == i+1 || strings.TrimSpace(strings.Join(lines[i+1:end], "")) == "" {
+			out = append(out, line)
+			continue
+		}
+
+		// The paragraph keeps a single colon, or none if the marker stands
+		// apart, and the literal block becomes a code block after it
+		switch {
+		case trimmed == "::":
+		case strings.HasSuffix(trimmed, " ::"):
+			out = append(out, strings.TrimSuffix(strings.TrimRight(line, " "), " ::"))
+		default:
+			out = append(out, strings.TrimSuffix(strings.TrimRight(line, " "), ":"))
+		}
+		listing := &codeListing{Language: language, Lines: dedentLines(trimBlank(lines[i+1:end]), "")}
+		indent := strings.Repeat(" ", paragraphIndent(lines, i))
+		out = append(out, "")
+		out = append(out, rawMarkdown(indent, c.render(listing))...)
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// blockEnd returns the index of the first line after the explicit markup
+// block starting at start, indented by indent.
+func blockEnd(lines []string, start, indent int) int {
+	end := start + 1
+	for end < len(lines) && (strings.TrimSpace(lines[end]) == "" || len(lines[end])-len(strings.TrimLeft(lines[end], " \t")) > indent) {
+		end++
+	}
+	for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return end
+}
+
+// parseDirectiveBlock parses the lines of a directive indented by indent.
+func parseDirectiveBlock(lines []string, indent int) *rst.Directive {
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent {
+			dedented[i] = line[indent:]
+		}
+	}
+	doc := rst.Parse(strings.Join(dedented, "\n"))
+	if len(doc.Children) == 0 {
+		return nil
+	}
+	d, _ := doc.Children[0].(*rst.Directive)
+	return d
+}
+
+// paragraphIndent returns the indentation of the content of the paragraph
+// whose line is at i, past any list marker opening it.
+func paragraphIndent(lines []string, i int) int {
+	start := i
+	for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+	}
+	line := lines[i]
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if start == i {
+		if m := listMarkerRegex.FindString(line[indent:]); m != "" {
+			indent += len(m)
+		}
+	}
+	return indent
+}
+
+// trimBlank removes leading and trailing blank lines.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// directiveListing collects the code and options of a code directive, using
+// language if it names none.
+func directiveListing(d *rst.Directive, language string) *codeListing {
+	listing := &codeListing{Language: language, Lines: d.Content}
+	if d.Argument != "" {
+		listing.Language = highlightLanguage(d.Argument)
+	}
+	for _, o := range d.Options {
+		switch o.Name {
+		case "caption":
+			listing.Caption = o.Value
+		case "name":
+			listing.Name = o.Value
+		case "linenos":
+			listing.LineNos = true
+		case "lineno-start", "number-lines":
+			// number-lines is the docutils code directive's, with an
+			// optional start
+			listing.LineNos = true
+			listing.LineNoStart, _ = strconv.Atoi(o.Value)
+		case "emphasize-lines":
+			for _, part := range strings.Split(o.Value, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					listing.Highlight = append(listing.Highlight, part)
+				}
+			}
+		case "dedent":
+			listing.Lines = dedentLines(listing.Lines, o.Value)
+		}
+	}
+	if listing.LineNoStart == 1 {
+		listing.LineNoStart = 0
+	}
+	return listing
+}
+
+// render writes a code listing in the configured style, preceded by an
+// anchor for its name and its caption.
+func (c *CodeBlocks) render(listing *codeListing) string {
+	var parts []string
+	if listing.Name != "" {
+		parts = append(parts, fmt.Sprintf("<a id=\"%s\"></a>", utils.HeadingAnchor(listing.Name)))
+	}
+	if listing.Caption != "" {
+		parts = append(parts, "*"+markdownEscapeReg.ReplaceAllString(listing.Caption, `\$1`)+"*")
+	}
+
+	code := strings.Join(listing.Lines, "\n")
+	language := listing.Language
+	if c.Style == CodeBlockShortcode {
+		var options []string
+		if listing.LineNos {
+			options = append(options, "linenos=table")
+		}
+		if len(listing.Highlight) > 0 {
+			options = append(options, "hl_lines="+strings.Join(listing.Highlight, " "))
+		}
+		if listing.LineNoStart != 0 {
+			options = append(options, "linenostart="+strconv.Itoa(listing.LineNoStart))
+		}
+		if language == "" {
+			language = "text"
+		}
+		shortcode := "{{< highlight " + language
+		if len(options) > 0 {
+			shortcode += fmt.Sprintf(" %q", strings.Join(options, ","))
+		}
+		parts = append(parts, shortcode+" >}}\n"+code+"\n{{< /highlight >}}")
+		return strings.Join(parts, "\n\n")
+	}
+
+	var attrs []string
+	if listing.LineNos {
+		attrs = append(attrs, "linenos=table")
+	}
+	if len(listing.Highlight) > 0 {
+		var lines []string
+		for _, h := range listing.Highlight {
+			if _, err := strconv.Atoi(h); err == nil {
+				lines = append(lines, h)
+			} else {
+				lines = append(lines, strconv.Quote(h))
+			}
+		}
+		attrs = append(attrs, "hl_lines=["+strings.Join(lines, ",")+"]")
+	}
+	if listing.LineNoStart != 0 {
+		attrs = append(attrs, "linenostart="+strconv.Itoa(listing.LineNoStart))
+	}
+	info := language
+	if len(attrs) > 0 {
+		if info == "" {
+			info = "text"
+		}
+		info += " {" + strings.Join(attrs, ",") + "}"
+	}
+
+	width := 3
+	for _, run := range backtickFenceRegex.FindAllString(code, -1) {
+		if len(run) >= width {
+			width = len(run) + 1
+		}
+	}
+	fence := strings.Repeat("`", width)
+	parts = append(parts, fence+info+"\n"+code+"\n"+fence)
+	return strings.Join(parts, "\n\n")
+}
+
+// rawMarkdown wraps Markdown in a raw directive at indent, which both
+// converters pass through unchanged.
+func rawMarkdown(indent, md string) []string {
+	out := []string{indent + ".. raw:: html", ""}
+	for _, line := range strings.Split(md, "\n") {
+		if line == "" {
+			out = append(out, "")
+		} else {
+			out = append(out, indent+"   "+line)
+		}
+	}
+	return append(out, "")
+}
@@ -0,0 +1,60 @@
+package processor
+
+import "testing"
+
+func TestCodeBlocksRewrite(t *testing.T) {
+	options := ".. code-block:: python\n   :caption: Say *hi*\n   :name: hello-code\n   :linenos:\n   :lineno-start: 10\n" +
+		"   :emphasize-lines: 2, 4-5\n\n   def hello():\n       print(\"hi\")\n"
+
+	tests := []struct {
+		name     string
+		style    string
+		language string
+		src      string
+		want     string
+	}{
+		{
+			name:  "fence with attributes",
+			style: CodeBlockFence,
+			src:   options,
+			want: ".. raw:: html\n\n   <a id=\"hello-code\"></a>\n\n   *Say \\*hi\\**\n\n" +
+				"   ```python {linenos=table,hl_lines=[2,\"4-5\"],linenostart=10}\n   def hello():\n       print(\"hi\")\n   ```\n\n",
+		},
+		{
+			name:  "highlight shortcode",
+			style: CodeBlockShortcode,
+			src:   ".. sourcecode:: go\n   :linenos:\n   :emphasize-lines: 1\n\n   x := 1",
+			want:  ".. raw:: html\n\n   {{< highlight go \"linenos=table,hl_lines=1\" >}}\n   x := 1\n   {{< /highlight >}}\n",
+		},
+		{
+			name:     "default language from the configuration and highlight",
+			style:    CodeBlockFence,
+			language: "python3",
+			src:      ".. code-block::\n\n   a = 1\n\n.. highlight:: console\n\n- Run::\n\n      $ make\n",
+			want: ".. raw:: html\n\n   ```python\n   a = 1\n   ```\n\n\n\n- Run:\n\n" +
+				"  .. raw:: html\n\n     ```console\n     $ make\n     ```\n\n",
+		},
+		{
+			name:  "literal blocks are left alone without a default language",
+			style: CodeBlockFence,
+			src:   "Example::\n\n   .. code-block:: go\n\n      x := 1\n",
+			want:  "Example::\n\n   .. code-block:: go\n\n      x := 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCodeBlocks(tt.style, tt.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Rewrite(tt.src); got != tt.want {
+				t.Errorf("Rewrite() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewCodeBlocks("bogus", ""); err == nil {
+		t.Error("NewCodeBlocks() accepted an unknown style")
+	}
+}
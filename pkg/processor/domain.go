@@ -0,0 +1,495 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+)
+
+// domainObjectTypes are the object directives of the Sphinx domains rst2md
+// renders, keyed by domain. Directives without a domain are Python ones, as
+// in Sphinx.
+var domainObjectTypes = map[string]map[string]bool{
+	"py": setOf("module", "currentmodule", "function", "class", "method", "classmethod", "staticmethod",
+		"attribute", "property", "data", "exception", "decorator", "decoratormethod", "type"),
+	"c": setOf("function", "macro", "struct", "union", "enum", "enumerator", "type", "member", "var"),
+	"cpp": setOf("function", "class", "struct", "union", "enum", "enum-class", "enum-struct", "enumerator",
+		"member", "var", "type", "concept"),
+	"js":   setOf("module", "function", "method", "class", "data", "attribute"),
+	"http": setOf("get", "post", "put", "patch", "delete", "head", "options", "trace", "connect", "copy", "any"),
+}
+
+// standardDirectives are the docutils directives whose names are also object
+// types of the default domain. They keep their docutils meaning unless
+// written with a domain, such as py:class.
+var standardDirectives = setOf("class")
+
+// domainLanguages are the languages signatures are highlighted in.
+var domainLanguages = map[string]string{"py": "python", "c": "c", "cpp": "cpp", "js": "javascript", "http": "http"}
+
+// domainPrefixes are the words Sphinx shows before the signatures of some
+// object types.
+var domainPrefixes = map[string]string{
+	"class": "class ", "exception": "exception ", "classmethod": "classmethod ", "staticmethod": "static ",
+	"property": "property ", "struct": "struct ", "union": "union ", "enum": "enum ", "concept": "concept ",
+	"enum-class": "enum class ", "enum-struct": "enum struct ",
+}
+
+// domainRoles are the cross-reference roles of the domains, which link to
+// objects of any type.
+var domainRoles = setOf("func", "class", "meth", "attr", "data", "exc", "mod", "obj", "const", "member",
+	"macro", "struct", "union", "enum", "enumerator", "type", "var", "concept",
+	"get", "post", "put", "patch", "delete", "head", "options", "trace", "connect", "copy", "any")
+
+var (
+	domainDirectiveRegex = regexp.MustCompile(`^(\s*)\.\.\s+(?:(py|c|cpp|js|http):)?([a-z-]+)::(?:\s+.*)?$`)
+	domainRoleRegex      = regexp.MustCompile("(?s):(?:(py|c|cpp|js|http):)?([a-z]+):`((?:[^`\\\\]|\\\\.)+)`")
+	infoFieldRegex       = regexp.MustCompile(`^:([^:\s][^:]*):(?:\s+(.*))?$`)
+	pyNameRegex          = regexp.MustCompile(`^\s*(?:async\s+)?@?([\w.]+)`)
+	cNameRegex           = regexp.MustCompile(`[A-Za-z_~][\w:~]*`)
+	typeNameRegex        = regexp.MustCompile(`^~?[\w.]+$`)
+	anchorRunRegex       = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+func setOf(names ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// domainObject is an object described by a domain directive.
+type domainObject struct {
+	Domain string
+	Type   string
+	Name   string // Full name, such as pkg.mod.Class.method or GET /users
+	Label  string // Label and anchor the object is linked to with
+}
+
+// domainContext is the module and class the objects of a directive's content
+// belong to.
+type domainContext struct {
+	module string
+	class  string
+}
+
+// domainLabel returns the label and anchor of the object named name in
+// domain: the domain and the lower-case name, with every run of other
+// characters than letters, digits and underscores replaced by a hyphen.
+func domainLabel(domain, name string) string {
+	return domain + "-" + strings.Trim(anchorRunRegex.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// RewriteDomains returns the RST src with its Sphinx domain directives, such
+// as py:function, c:function, js:class and http:get, replaced by an anchor
+// and a code block with their signatures, followed by their content with the
+// info fields (:param:, :returns:, :raises:, :query: and others) turned into
+// parameter tables and sections.
+func RewriteDomains(src string) string {
+	lines, _ := rewriteDomains(strings.Split(src, "\n"), &domainContext{})
+	return strings.Join(lines, "\n")
+}
+
+// domainObjects returns the objects the domain directives of src describe.
+func domainObjects(src string) []domainObject {
+	_, objects := rewriteDomains(strings.Split(src, "\n"), &domainContext{})
+	return objects
+}
+
+// rewriteDomains rewrites the domain directives of lines, whose objects
+// belong to ctx, and returns the objects they describe.
+func rewriteDomains(lines []string, ctx *domainContext) ([]string, []domainObject) {
+	literal := rst.LiteralLines(lines)
+	var out []string
+	var objects []domainObject
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		m := domainDirectiveRegex.FindStringSubmatch(line)
+		if literal[i] || m == nil {
+			out = append(out, line)
+			continue
+		}
+		domain, typ := m[2], m[3]
+		if domain == "" && standardDirectives[typ] {
+			out = append(out, line)
+			continue
+		}
+		if domain == "" {
+			domain = "py"
+		}
+		if !domainObjectTypes[domain][typ] {
+			out = append(out, line)
+			continue
+		}
+		end := blockEnd(lines, i, len(m[1]))
+		d := parseDirectiveBlock(lines[i:end], len(m[1]))
+		i = end - 1
+		if d == nil {
+			continue
+		}
+		_, noIndex := d.Option("noindex")
+		if _, ok := d.Option("no-index"); ok {
+			noIndex = true
+		}
+
+		if typ == "module" || typ == "currentmodule" {
+			ctx.module = strings.TrimSpace(d.Argument)
+			if typ == "module" && !noIndex && ctx.module != "" {
+				object := domainObject{Domain: domain, Type: typ, Name: ctx.module, Label: domainLabel(domain, ctx.module)}
+				objects = append(objects, object)
+				out = append(out, rawMarkdown(m[1], fmt.Sprintf("<a id=\"%s\"></a>", object.Label))...)
+			}
+			continue
+		}
+
+		objCtx := *ctx
+		if module, ok := d.Option("module"); ok {
+			objCtx.module = module
+		}
+		var anchors, signatures []string
+		for _, sig := range strings.Split(d.Argument, "\n") {
+			if sig = strings.TrimSpace(sig); sig == "" {
+				continue
+			}
+			display, name := domainSignature(domain, typ, sig, &objCtx)
+			signatures = append(signatures, display)
+			if noIndex || name == "" {
+				continue
+			}
+			object := domainObject{Domain: domain, Type: typ, Name: name, Label: domainLabel(domain, name)}
+			objects = append(objects, object)
+			anchors = append(anchors, fmt.Sprintf("<a id=\"%s\"></a>", object.Label))
+		}
+		var parts []string
+		if len(anchors) > 0 {
+			parts = append(parts, strings.Join(anchors, ""))
+		}
+		parts = append(parts, "```"+domainLanguages[domain]+"\n"+strings.Join(signatures, "\n")+"\n```")
+		out = append(out, rawMarkdown(m[1], strings.Join(parts, "\n\n"))...)
+
+		// The content of classes documents their members
+		childCtx := objCtx
+		if len(objects) > 0 && (typ == "class" || typ == "exception" || typ == "struct") {
+			childCtx.class = objects[len(objects)-1].Name
+		}
+		content, children := rewriteDomains(rewriteInfoFields(d.Content, domain), &childCtx)
+		objects = append(objects, children...)
+		for _, line := range trimBlank(content) {
+			if line == "" {
+				out = append(out, "")
+			} else {
+				out = append(out, m[1]+line)
+			}
+		}
+		out = append(out, "")
+	}
+	return out, objects
+}
+
+// domainSignature returns how the signature sig of a domain directive is
+// shown, and the full name of the object it describes.
+func domainSignature(domain, typ, sig string, ctx *domainContext) (string, string) {
+	switch domain {
+	case "http":
+		method := strings.ToUpper(typ)
+		return method + " " + sig, method + " " + strings.Fields(sig)[0]
+
+	case "c", "cpp":
+		decl := sig
+		if i := strings.Index(decl, "("); i >= 0 && typ != "type" {
+			decl = decl[:i]
+		}
+		decl, _, _ = strings.Cut(decl, "=")
+		names := cNameRegex.FindAllString(decl, -1)
+		if len(names) == 0 {
+			return domainPrefixes[typ] + sig, ""
+		}
+		name := names[len(names)-1]
+		if ctx.class != "" && !strings.Contains(name, "::") {
+			name = ctx.class + "::" + name
+		}
+		return domainPrefixes[typ] + sig, name
+	}
+
+	// Python and JavaScript
+	m := pyNameRegex.FindStringSubmatch(sig)
+	if m == nil {
+		return domainPrefixes[typ] + sig, ""
+	}
+	name, display := m[1], sig
+	switch {
+	case ctx.class != "":
+		name = ctx.class + "." + name
+	case ctx.module != "":
+		name = ctx.module + "." + name
+		display = ctx.module + "." + sig
+	}
+	return domainPrefixes[typ] + display, name
+}
+
+// infoField is a field of an info field list, such as :param int x: Size.
+type infoField struct {
+	Kind string   // First word of the field name
+	Arg  string   // Rest of the field name
+	Body []string // Field body, dedented
+}
+
+// infoSection is a section info fields are gathered into.
+type infoSection struct {
+	Title  string
+	Header string // Header of the name column, if the section is a table
+}
+
+// infoSections are the sections info fields are gathered into, in the order
+// they are written, keyed by field kind.
+var (
+	infoSectionOrder = []infoSection{
+		{"Parameters", "Name"}, {"Variables", "Name"}, {"Query parameters", "Name"},
+		{"Form parameters", "Name"}, {"Request headers", "Header"}, {"Response headers", "Header"},
+		{"Request JSON", "Field"}, {"Response JSON", "Field"}, {"Status codes", "Code"},
+		{"Returns", ""}, {"Return type", ""}, {"Yields", ""}, {"Yield type", ""}, {"Raises", ""},
+	}
+	infoFieldSections = map[string]string{
+		"param": "Parameters", "parameter": "Parameters", "arg": "Parameters", "argument": "Parameters",
+		"key": "Parameters", "keyword": "Parameters",
+		"var": "Variables", "ivar": "Variables", "cvar": "Variables",
+		"query": "Query parameters", "queryparam": "Query parameters", "queryparameter": "Query parameters",
+		"form": "Form parameters", "formparam": "Form parameters", "formparameter": "Form parameters",
+		"reqheader": "Request headers", "requestheader": "Request headers",
+		"resheader": "Response headers", "responseheader": "Response headers",
+		"reqjson": "Request JSON", "<json": "Request JSON", "reqjsonobj": "Request JSON", "<jsonarr": "Request JSON",
+		"resjson": "Response JSON", ">json": "Response JSON", "resjsonobj": "Response JSON", ">jsonarr": "Response JSON",
+		"status": "Status codes", "statuscode": "Status codes", "code": "Status codes",
+		"returns": "Returns", "return": "Returns", "rtype": "Return type",
+		"yields": "Yields", "yield": "Yields", "ytype": "Yield type",
+		"raises": "Raises", "raise": "Raises", "except": "Raises", "exception": "Raises",
+	}
+	// infoTypeFields give the types of the parameters and variables named
+	// by their argument.
+	infoTypeFields = map[string]bool{"type": true, "vartype": true, "kwtype": true}
+)
+
+// rewriteInfoFields returns the content of a domain directive with its info
+// field lists replaced by tables and sections. Field lists without info
+// fields are kept, as are the fields rst2md does not know in the lists it
+// rewrites.
+func rewriteInfoFields(lines []string, domain string) []string {
+	literal := rst.LiteralLines(lines)
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if literal[i] || !infoFieldRegex.MatchString(lines[i]) {
+			out = append(out, lines[i])
+			continue
+		}
+		end := i
+		var fields []infoField
+		for end < len(lines) {
+			m := infoFieldRegex.FindStringSubmatch(lines[end])
+			if m == nil {
+				break
+			}
+			next := end + 1
+			for next < len(lines) && (strings.TrimSpace(lines[next]) == "" || strings.HasPrefix(lines[next], " ") || strings.HasPrefix(lines[next], "\t")) {
+				next++
+			}
+			for next > end+1 && strings.TrimSpace(lines[next-1]) == "" {
+				next--
+			}
+			words := strings.Fields(m[1])
+			body := append([]string{m[2]}, dedentLines(lines[end+1:next], "")...)
+			fields = append(fields, infoField{Kind: strings.ToLower(words[0]), Arg: strings.Join(words[1:], " "), Body: trimBlank(body)})
+			end = next
+			for end < len(lines) && strings.TrimSpace(lines[end]) == "" && end+1 < len(lines) && infoFieldRegex.MatchString(lines[end+1]) {
+				end++
+			}
+		}
+		out = append(out, renderInfoFields(fields, lines[i:end], domain)...)
+		i = end - 1
+	}
+	return out
+}
+
+// renderInfoFields renders the info fields of a field list, whose source is
+// src, as RST sections.
+func renderInfoFields(fields []infoField, src []string, domain string) []string {
+	types := map[string]string{}
+	sections := map[string][]infoField{}
+	var unknown []string
+	known := false
+	for _, f := range fields {
+		switch {
+		case infoTypeFields[f.Kind]:
+			types[f.Arg] = strings.Join(f.Body, " ")
+			known = true
+		case infoFieldSections[f.Kind] != "":
+			sections[infoFieldSections[f.Kind]] = append(sections[infoFieldSections[f.Kind]], f)
+			known = true
+		default:
+			unknown = append(unknown, ":"+strings.TrimSpace(f.Kind+" "+f.Arg)+": "+strings.Join(f.Body, "\n   "))
+		}
+	}
+	if !known {
+		return src
+	}
+
+	var out []string
+	for _, section := range infoSectionOrder {
+		fields := sections[section.Title]
+		if len(fields) == 0 {
+			continue
+		}
+		out = append(out, "**"+section.Title+"**", "")
+		switch {
+		case section.Header != "":
+			out = append(out, infoTable(section.Header, fields, types, domain)...)
+		case section.Title == "Raises":
+			for _, f := range fields {
+				item := typeReference(domain, "exc", f.Arg)
+				if len(f.Body) > 0 && f.Body[0] != "" {
+					item += " – " + f.Body[0]
+				}
+				out = append(out, "- "+item)
+				for _, line := range f.Body[min(1, len(f.Body)):] {
+					out = append(out, indentLine("  ", line))
+				}
+			}
+		case strings.HasSuffix(section.Title, "type"):
+			out = append(out, typeReference(domain, "class", strings.Join(fields[0].Body, " ")))
+		default:
+			out = append(out, fields[0].Body...)
+		}
+		out = append(out, "")
+	}
+	if len(unknown) > 0 {
+		out = append(out, unknown...)
+		out = append(out, "")
+	}
+	return trimBlank(out)
+}
+
+// infoTable renders fields as a list-table of their names, types if any has
+// one, and descriptions.
+func infoTable(header string, fields []infoField, types map[string]string, domain string) []string {
+	type row struct{ name, typ string }
+	rows := make([]row, len(fields))
+	hasTypes := false
+	for i, f := range fields {
+		words := strings.Fields(f.Arg)
+		name, typ := f.Arg, types[f.Arg]
+		if len(words) > 1 {
+			// :param int x: and :<json string name:
+			name, typ = words[len(words)-1], strings.Join(words[:len(words)-1], " ")
+		}
+		rows[i] = row{name, typ}
+		hasTypes = hasTypes || typ != ""
+	}
+
+	cells := [][]string{{header}}
+	if hasTypes {
+		cells = append(cells, []string{"Type"})
+	}
+	cells = append(cells, []string{"Description"})
+	out := []string{".. list-table::", "   :header-rows: 1", ""}
+	out = append(out, listTableRow(cells)...)
+	for i, f := range fields {
+		cells := [][]string{{"``" + rows[i].name + "``"}}
+		if hasTypes {
+			cells = append(cells, []string{typeReference(domain, "class", rows[i].typ)})
+		}
+		cells = append(cells, f.Body)
+		out = append(out, listTableRow(cells)...)
+	}
+	return out
+}
+
+// listTableRow returns the lines of a list-table row holding cells.
+func listTableRow(cells [][]string) []string {
+	var out []string
+	for i, cell := range cells {
+		marker := "     - "
+		if i == 0 {
+			marker = "   * - "
+		}
+		if len(cell) == 0 || cell[0] == "" {
+			out = append(out, strings.TrimRight(marker, " "))
+		} else {
+			out = append(out, marker+cell[0])
+		}
+		for _, line := range cell[min(1, len(cell)):] {
+			out = append(out, indentLine("       ", line))
+		}
+	}
+	return out
+}
+
+// indentLine indents line by indent unless it is blank.
+func indentLine(indent, line string) string {
+	if strings.TrimSpace(line) == "" {
+		return ""
+	}
+	return indent + line
+}
+
+// typeReference returns a type or exception name as a cross-reference with
+// role in the Python domain, where Sphinx links them, and as is otherwise.
+func typeReference(domain, role, name string) string {
+	if domain == "py" && typeNameRegex.MatchString(name) {
+		return ":py:" + role + ":`" + name + "`"
+	}
+	return name
+}
+
+// domainReference returns the link text and placeholder target of a domain
+// cross-reference role, and false if no documented object matches it.
+func (ix *LabelIndex) domainReference(domain, role, target string) (string, string, bool) {
+	title, target, explicit := rst.SplitEmbedded(target)
+	noLink := strings.HasPrefix(target, "!")
+	target = strings.TrimLeft(target, "!")
+	short := strings.HasPrefix(target, "~")
+	target = strings.TrimLeft(target, "~.")
+
+	name := target
+	if domain == "http" {
+		name = strings.ToUpper(role) + " " + target
+	}
+	text := name
+	if short {
+		text = text[strings.LastIndexAny(text, ".:")+1:]
+	}
+	if (role == "func" || role == "meth") && !strings.HasSuffix(text, ")") {
+		text += "()"
+	}
+	if explicit {
+		text = title
+	}
+	if noLink {
+		return text, "", false
+	}
+
+	label := domainLabel(domain, name)
+	if _, ok := ix.Labels[label]; ok {
+		return text, refScheme + label, true
+	}
+	// Names may be given relative to their module or class
+	var matches []string
+	suffix := "-" + strings.TrimPrefix(label, domain+"-")
+	for key := range ix.Labels {
+		if strings.HasPrefix(key, domain+"-") && strings.HasSuffix(key, suffix) {
+			matches = append(matches, key)
+		}
+	}
+	if len(matches) == 0 {
+		return text, "", false
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if len(matches[i]) != len(matches[j]) {
+			return len(matches[i]) < len(matches[j])
+		}
+		return matches[i] < matches[j]
+	})
+	return text, refScheme + matches[0], true
+}
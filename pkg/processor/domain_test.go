@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestRewriteDomains(t *testing.T) {
+	src := ".. py:module:: mod\n\n.. py:function:: add(a, b=1) -> int\n\n   Add numbers.\n\n" +
+		"   :param int a: The first.\n   :param b: The second.\n   :type b: float\n   :returns: The sum.\n   :raises ValueError: If negative.\n\n" +
+		".. http:get:: /users/(int:id)\n\n   :statuscode 404: Unknown.\n"
+	want := ".. raw:: html\n\n   <a id=\"py-mod\"></a>\n\n\n" +
+		".. raw:: html\n\n   <a id=\"py-mod-add\"></a>\n\n   ```python\n   mod.add(a, b=1) -> int\n   ```\n\n" +
+		"Add numbers.\n\n**Parameters**\n\n.. list-table::\n   :header-rows: 1\n\n" +
+		"   * - Name\n     - Type\n     - Description\n" +
+		"   * - ``a``\n     - :py:class:`int`\n     - The first.\n" +
+		"   * - ``b``\n     - :py:class:`float`\n     - The second.\n\n" +
+		"**Returns**\n\nThe sum.\n\n**Raises**\n\n- :py:exc:`ValueError` – If negative.\n\n\n" +
+		".. raw:: html\n\n   <a id=\"http-get-users-int-id\"></a>\n\n   ```http\n   GET /users/(int:id)\n   ```\n\n" +
+		"**Status codes**\n\n.. list-table::\n   :header-rows: 1\n\n" +
+		"   * - Code\n     - Description\n   * - ``404``\n     - Unknown.\n\n"
+	if got := RewriteDomains(src); got != want {
+		t.Errorf("RewriteDomains() =\n%q\nwant\n%q", got, want)
+	}
+
+	// The docutils class directive is not a Python class
+	src = ".. class:: special\n\n   Styled paragraph.\n"
+	if got := RewriteDomains(src); got != src {
+		t.Errorf("RewriteDomains() =\n%q\nwant\n%q", got, src)
+	}
+}
+
+func TestDomainObjects(t *testing.T) {
+	src := ".. py:currentmodule:: pkg.mod\n\n.. py:class:: Calc(x)\n\n   .. py:method:: total()\n\n" +
+		".. function:: helper()\n   :noindex:\n\n.. class:: special\n\n   Styled paragraph.\n\n.. c:function:: int sum_ints(int *values)\n\n.. cpp:class:: Vec\n\n   .. cpp:function:: size_t size() const\n"
+	want := []domainObject{
+		{Domain: "py", Type: "class", Name: "pkg.mod.Calc", Label: "py-pkg-mod-calc"},
+		{Domain: "py", Type: "method", Name: "pkg.mod.Calc.total", Label: "py-pkg-mod-calc-total"},
+		{Domain: "c", Type: "function", Name: "sum_ints", Label: "c-sum_ints"},
+		{Domain: "cpp", Type: "class", Name: "Vec", Label: "cpp-vec"},
+		{Domain: "cpp", Type: "function", Name: "Vec::size", Label: "cpp-vec-size"},
+	}
+	if got := domainObjects(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("domainObjects() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDomainReferences(t *testing.T) {
+	ix := &LabelIndex{Labels: map[string]types.Label{}, Titles: map[string]string{}}
+	ix.AddDocument("api", ".. py:module:: mod\n\n.. py:class:: Calc\n\n   .. py:method:: total()\n")
+
+	src := ":py:class:`mod.Calc`, :meth:`~mod.Calc.total`, :meth:`Calc.total`, :func:`len` and :class:`the calculator <mod.Calc>`."
+	want := "`mod.Calc <rst2md-ref:py-mod-calc>`__, `total() <rst2md-ref:py-mod-calc-total>`__, " +
+		"`Calc.total() <rst2md-ref:py-mod-calc-total>`__, ``len()`` and `the calculator <rst2md-ref:py-mod-calc>`__."
+	if got := ix.RewriteReferences("guide", src); got != want {
+		t.Errorf("RewriteReferences() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+var (
+	footnoteDefRegex = regexp.MustCompile(`^\[\^([^\]\s]+)\]:[ \t]?`)
+	footnoteRefRegex = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// footnote is a footnote or citation definition taken out of a section.
+type footnote struct {
+	label string
+	lines []string // The definition, its first line without the label
+}
+
+// RehomeFootnotes moves the footnote and citation definitions, which the
+// converters append to the end of the document and so to its last section,
+// to the end of every section referencing them. Numbered footnotes are
+// renumbered from 1 in the order they are first referenced on each page;
+// citations keep their labels. Definitions no section references are dropped,
+// as Hugo does not render them either.
+func RehomeFootnotes(sections []types.Section) []types.Section {
+	definitions := map[string]*footnote{}
+	bodies := make([][]string, len(sections))
+	for i, section := range sections {
+		var notes []*footnote
+		bodies[i], notes = extractFootnotes(section.Content)
+		for _, note := range notes {
+			if _, ok := definitions[note.label]; !ok {
+				definitions[note.label] = note
+			}
+		}
+	}
+	if len(definitions) == 0 {
+		return sections
+	}
+
+	result := make([]types.Section, len(sections))
+	for i, section := range sections {
+		labels := map[string]string{}
+		var used []*footnote
+		number := 0
+		forEachText(bodies[i], func(text string) string {
+			for _, m := range footnoteRefRegex.FindAllStringSubmatch(text, -1) {
+				note, ok := definitions[m[1]]
+				if _, seen := labels[m[1]]; seen || !ok {
+					continue
+				}
+				labels[m[1]] = m[1]
+				if _, err := strconv.Atoi(m[1]); err == nil {
+					number++
+					labels[m[1]] = strconv.Itoa(number)
+				}
+				used = append(used, note)
+			}
+			return text
+		})
+
+		body := forEachText(bodies[i], func(text string) string {
+			return footnoteRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+				if label, ok := labels[footnoteRefRegex.FindStringSubmatch(match)[1]]; ok {
+					return "[^" + label + "]"
+				}
+				return match
+			})
+		})
+		var notes []string
+		for _, note := range used {
+			notes = append(notes, renderFootnote(labels[note.label], note.lines))
+		}
+		result[i] = types.Section{Title: section.Title, Content: appendFootnotes(body, notes)}
+	}
+	return result
+}
+
+// extractFootnotes returns the lines of content without its footnote
+// definitions, and the definitions. A definition runs until the first line
+// after it that is neither blank nor indented.
+func extractFootnotes(content string) ([]string, []*footnote) {
+	lines := strings.Split(content, "\n")
+	var body []string
+	var notes []*footnote
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1] == fence {
+				fence = ""
+			}
+		}
+		m := footnoteDefRegex.FindStringSubmatch(line)
+		if fence != "" || m == nil {
+			body = append(body, line)
+			continue
+		}
+
+		note := &footnote{label: m[1], lines: []string{line[len(m[0]):]}}
+		end := i + 1
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "" {
+				continue
+			}
+			if !strings.HasPrefix(lines[j], "    ") && !strings.HasPrefix(lines[j], "\t") {
+				break
+			}
+			end = j + 1
+		}
+		note.lines = append(note.lines, lines[i+1:end]...)
+		notes = append(notes, note)
+		i = end - 1
+	}
+	return body, notes
+}
+
+// forEachText applies fn to every line of lines outside fenced code blocks
+// and returns the result joined.
+func forEachText(lines []string, fn func(string) string) string {
+	out := make([]string, len(lines))
+	fence := ""
+	for i, line := range lines {
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1] == fence {
+				fence = ""
+			}
+			out[i] = line
+			continue
+		}
+		if fence != "" {
+			out[i] = line
+			continue
+		}
+		out[i] = fn(line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderFootnote renders the definition of the footnote with label.
+func renderFootnote(label string, lines []string) string {
+	return strings.TrimRight("[^"+label+"]: "+strings.Join(lines, "\n"), "\n ")
+}
+
+// appendFootnotes returns body followed by the footnote definitions in notes.
+func appendFootnotes(body string, notes []string) string {
+	content := strings.TrimRight(body, "\n")
+	if len(notes) == 0 {
+		return content + "\n"
+	}
+	return content + "\n\n" + strings.Join(notes, "\n\n") + "\n"
+}
@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestRehomeFootnotes(t *testing.T) {
+	sections := []types.Section{
+		{Title: "Guide", Content: "Intro[^1] and [^CIT2002].\n"},
+		{Title: "First", Content: "See[^2] and again[^1].\n\n```\n[^3]: code\n```\n"},
+		{Title: "Second", Content: "Only[^2].\n\n[^1]: Note one.\n\n    Second paragraph.\n\n[^2]: Note two.\n\n[^CIT2002]: A citation.\n\n[^4]: Unused.\n"},
+	}
+	want := []types.Section{
+		{Title: "Guide", Content: "Intro[^1] and [^CIT2002].\n\n[^1]: Note one.\n\n    Second paragraph.\n\n[^CIT2002]: A citation.\n"},
+		{Title: "First", Content: "See[^1] and again[^2].\n\n```\n[^3]: code\n```\n\n[^1]: Note two.\n\n[^2]: Note one.\n\n    Second paragraph.\n"},
+		{Title: "Second", Content: "Only[^1].\n\n[^1]: Note two.\n"},
+	}
+	if got := RehomeFootnotes(sections); !reflect.DeepEqual(got, want) {
+		t.Errorf("RehomeFootnotes() =\n%q\nwant\n%q", got, want)
+	}
+
+	plain := []types.Section{{Title: "Plain", Content: "No notes [^x] here.\n"}}
+	if got := RehomeFootnotes(plain); !reflect.DeepEqual(got, plain) {
+		t.Errorf("RehomeFootnotes() = %q, want %q", got, plain)
+	}
+}
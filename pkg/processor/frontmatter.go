@@ -0,0 +1,282 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+// Front matter formats.
+const (
+	FrontMatterYAML = "yaml" // Between --- lines
+	FrontMatterTOML = "toml" // Between +++ lines
+	FrontMatterJSON = "json" // A JSON object
+)
+
+// reservedFrontMatter are the front matter keys rst2md writes itself, which
+// params cannot set.
+var reservedFrontMatter = map[string]bool{"title": true, "weight": true, "math": true}
+
+// FrontMatterFormat writes the front matter of the generated pages in the
+// configured format.
+type FrontMatterFormat struct {
+	Format string
+}
+
+// NewFrontMatterFormat returns the front matter writer for format.
+func NewFrontMatterFormat(format string) (*FrontMatterFormat, error) {
+	switch format {
+	case "", FrontMatterYAML:
+		format = FrontMatterYAML
+	case FrontMatterTOML, FrontMatterJSON:
+	default:
+		return nil, fmt.Errorf("unknown front matter format %q: want yaml, toml or json", format)
+	}
+	return &FrontMatterFormat{Format: format}, nil
+}
+
+// Marshal returns fm with its delimiters: the title, the weight if non-zero
+// and math if set, followed by the params sorted by key. Values are quoted
+// as the format requires, so that titles such as "C#: *the* guide" survive.
+func (f *FrontMatterFormat) Marshal(fm types.FrontMatter) (string, error) {
+	entries := yaml.MapSlice{{Key: "title", Value: fm.Title}}
+	if fm.Weight != 0 {
+		entries = append(entries, yaml.MapItem{Key: "weight", Value: fm.Weight})
+	}
+	if fm.Math {
+		entries = append(entries, yaml.MapItem{Key: "math", Value: true})
+	}
+	var keys []string
+	for key := range fm.Params {
+		if !reservedFrontMatter[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entries = append(entries, yaml.MapItem{Key: key, Value: fm.Params[key]})
+	}
+
+	format := FrontMatterYAML
+	if f != nil {
+		format = f.Format
+	}
+	switch format {
+	case FrontMatterTOML:
+		return marshalTOML(entries)
+	case FrontMatterJSON:
+		return marshalJSON(entries)
+	}
+	out, err := yaml.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return "---\n" + string(out) + "---\n", nil
+}
+
+// frontMatterRule is a front matter rule with its glob and templates
+// compiled.
+type frontMatterRule struct {
+	pattern   *regexp.Regexp
+	params    map[string]interface{}
+	templates map[string]*template.Template
+}
+
+// FrontMatterRules adds the params of the configured rules to the front
+// matter of the pages of the documents they match.
+type FrontMatterRules struct {
+	rules []frontMatterRule
+}
+
+// NewFrontMatterRules compiles the globs and the templates of rules. A
+// string param holding {{ is a template.
+func NewFrontMatterRules(rules []config.FrontMatterRule) (*FrontMatterRules, error) {
+	r := &FrontMatterRules{}
+	for _, rule := range rules {
+		pattern, err := globRegexp(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid front matter rule path %q: %w", rule.Path, err)
+		}
+		compiled := frontMatterRule{pattern: pattern, params: map[string]interface{}{}, templates: map[string]*template.Template{}}
+		for key, value := range rule.Params {
+			text, ok := value.(string)
+			if !ok || !strings.Contains(text, "{{") {
+				compiled.params[key] = normalizeValue(value)
+				continue
+			}
+			tmpl, err := template.New(key).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid front matter template %s for %q: %w", key, rule.Path, err)
+			}
+			compiled.templates[key] = tmpl
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// Params returns the params of the rules matching the document of page, later
+// rules overriding earlier ones. Templates are executed with page as their
+// data; their output is typed as document fields are, and an empty output
+// removes the param.
+func (r *FrontMatterRules) Params(page types.PageInfo) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	if r == nil || page.DocName == "" {
+		return params, nil
+	}
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(page.DocName) {
+			continue
+		}
+		for key, value := range rule.params {
+			params[key] = value
+		}
+		for key, tmpl := range rule.templates {
+			var b strings.Builder
+			if err := tmpl.Execute(&b, page); err != nil {
+				return nil, err
+			}
+			if value := strings.TrimSpace(b.String()); value != "" {
+				params[key] = fieldValue(strings.ToLower(key), value)
+			} else {
+				delete(params, key)
+			}
+		}
+	}
+	return params, nil
+}
+
+// normalizeValue returns value with the maps YAML decodes nested mappings
+// into keyed by strings, as JSON and TOML require.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeValue(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = normalizeValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// marshalTOML returns entries as TOML front matter. Tables must follow every
+// plain key, so entries holding maps or lists of maps are written last.
+func marshalTOML(entries yaml.MapSlice) (string, error) {
+	var b bytes.Buffer
+	b.WriteString("+++\n")
+	tables := map[string]interface{}{}
+	for _, entry := range entries {
+		key := entry.Key.(string)
+		if isTable(entry.Value) {
+			tables[key] = entry.Value
+			continue
+		}
+		if err := toml.NewEncoder(&b).Encode(map[string]interface{}{key: entry.Value}); err != nil {
+			return "", err
+		}
+	}
+	if len(tables) > 0 {
+		if err := toml.NewEncoder(&b).Encode(tables); err != nil {
+			return "", err
+		}
+	}
+	b.WriteString("+++\n")
+	return b.String(), nil
+}
+
+// marshalJSON returns entries as a JSON object, one key per line in order.
+func marshalJSON(entries yaml.MapSlice) (string, error) {
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return "", err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = fmt.Sprintf("  %s: %s", key, value)
+	}
+	return "{\n" + strings.Join(lines, ",\n") + "\n}\n", nil
+}
+
+// isTable reports whether TOML writes value as a table: a map, or a
+// non-empty list of maps written as an array of tables.
+func isTable(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}, map[string]string:
+		return true
+	case []map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(map[string]interface{}); !ok {
+				return false
+			}
+		}
+		return len(v) > 0
+	}
+	return false
+}
+
+// splitFrontMatter separates the YAML, TOML or JSON front matter from the
+// page body, and returns its format. JSON front matter is returned with its
+// braces, YAML and TOML front matter without their delimiters.
+func splitFrontMatter(content string) (string, string, string) {
+	switch {
+	case strings.HasPrefix(content, "---\n"), strings.HasPrefix(content, "+++\n"):
+		format := FrontMatterYAML
+		if content[0] == '+' {
+			format = FrontMatterTOML
+		}
+		end := strings.Index(content[4:], "\n"+content[:3]+"\n")
+		if end < 0 {
+			return "", "", content
+		}
+		return format, content[4 : 4+end], content[4+end+5:]
+	case strings.HasPrefix(content, "{\n"):
+		end := strings.Index(content, "\n}\n")
+		if end < 0 {
+			return "", "", content
+		}
+		return FrontMatterJSON, content[:end+2], content[end+3:]
+	}
+	return "", "", content
+}
+
+// unmarshalFrontMatter decodes frontMatter in format, as returned by
+// splitFrontMatter, into v.
+func unmarshalFrontMatter(format, frontMatter string, v interface{}) error {
+	switch format {
+	case FrontMatterTOML:
+		_, err := toml.Decode(frontMatter, v)
+		return err
+	case FrontMatterJSON:
+		return json.Unmarshal([]byte(frontMatter), v)
+	}
+	return yaml.Unmarshal([]byte(frontMatter), v)
+}
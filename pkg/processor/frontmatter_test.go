@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestFrontMatterMarshal(t *testing.T) {
+	fm := types.FrontMatter{
+		Title:  `C#: "quoted" *guide*`,
+		Weight: 20,
+		Math:   true,
+		Params: map[string]interface{}{"tags": []string{"a", "b"}, "draft": false, "title": "ignored"},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: FrontMatterYAML,
+			want:   "---\ntitle: 'C#: \"quoted\" *guide*'\nweight: 20\nmath: true\ndraft: false\ntags:\n- a\n- b\n---\n",
+		},
+		{
+			format: FrontMatterTOML,
+			want:   "+++\ntitle = \"C#: \\\"quoted\\\" *guide*\"\nweight = 20\nmath = true\ndraft = false\ntags = [\"a\", \"b\"]\n+++\n",
+		},
+		{
+			format: FrontMatterJSON,
+			want:   "{\n  \"title\": \"C#: \\\"quoted\\\" *guide*\",\n  \"weight\": 20,\n  \"math\": true,\n  \"draft\": false,\n  \"tags\": [\"a\",\"b\"]\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			f, err := NewFrontMatterFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.Marshal(fm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			format, frontMatter, body := splitFrontMatter(got + "\nBody\n")
+			if format != tt.format || body != "\nBody\n" {
+				t.Errorf("splitFrontMatter() = %q, %q, want %q, %q", format, body, tt.format, "\nBody\n")
+			}
+			var meta struct {
+				Title string `yaml:"title" toml:"title" json:"title"`
+			}
+			if err := unmarshalFrontMatter(format, frontMatter, &meta); err != nil || meta.Title != fm.Title {
+				t.Errorf("unmarshalFrontMatter() title = %q, %v, want %q", meta.Title, err, fm.Title)
+			}
+		})
+	}
+
+	if _, err := NewFrontMatterFormat("xml"); err == nil {
+		t.Error("NewFrontMatterFormat(xml) succeeded, want an error")
+	}
+}
+
+func TestFrontMatterMarshalTOMLTables(t *testing.T) {
+	fm := types.FrontMatter{
+		Title: "Guide",
+		Params: map[string]interface{}{
+			"authors": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}},
+			"draft":   false,
+			"extra":   map[string]interface{}{"owner": "docs"},
+			"tags":    []interface{}{"x"},
+			"zone":    "eu",
+		},
+	}
+	f, err := NewFrontMatterFormat(FrontMatterTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.Marshal(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "+++\ntitle = \"Guide\"\ndraft = false\ntags = [\"x\"]\nzone = \"eu\"\n[[authors]]\n  name = \"a\"\n\n[[authors]]\n  name = \"b\"\n\n[extra]\n  owner = \"docs\"\n+++\n"
+	if got != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", got, want)
+	}
+
+	var meta struct {
+		Zone    string              `toml:"zone"`
+		Authors []map[string]string `toml:"authors"`
+	}
+	_, frontMatter, _ := splitFrontMatter(got)
+	if err := unmarshalFrontMatter(FrontMatterTOML, frontMatter, &meta); err != nil || meta.Zone != "eu" || len(meta.Authors) != 2 {
+		t.Errorf("unmarshalFrontMatter() = %+v, %v", meta, err)
+	}
+}
+
+func TestFrontMatterRules(t *testing.T) {
+	rules, err := NewFrontMatterRules([]config.FrontMatterRule{
+		{Path: "**", Params: map[string]interface{}{"status": "draft", "extra": map[interface{}]interface{}{"owner": "docs"}}},
+		{Path: "api/*", Params: map[string]interface{}{
+			"status": "{{ if .Section }}{{ else }}stable{{ end }}",
+			"order":  "{{ index .TOC 0 }}",
+			"source": "{{ .Source }}: {{ .DocTitle }} / {{ .Title }}",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		page types.PageInfo
+		want map[string]interface{}
+	}{
+		{
+			name: "main page",
+			page: types.PageInfo{Title: "Calc", DocTitle: "Calc", DocName: "api/calc", Source: "api/calc.rst", TOC: []int{2, 1}},
+			want: map[string]interface{}{"status": "stable", "order": 2, "source": "api/calc.rst: Calc / Calc", "extra": map[string]interface{}{"owner": "docs"}},
+		},
+		{
+			name: "section page",
+			page: types.PageInfo{Title: "Usage", DocTitle: "Calc", DocName: "api/calc", Source: "api/calc.rst", Section: 1, TOC: []int{2, 1}},
+			want: map[string]interface{}{"order": 2, "source": "api/calc.rst: Calc / Usage", "extra": map[string]interface{}{"owner": "docs"}},
+		},
+		{
+			name: "other document",
+			page: types.PageInfo{Title: "Guide", DocName: "api/sub/guide"},
+			want: map[string]interface{}{"status": "draft", "extra": map[string]interface{}{"owner": "docs"}},
+		},
+		{
+			name: "external link",
+			page: types.PageInfo{Title: "Home", Path: "home/_index.md"},
+			want: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rules.Params(tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Params() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewFrontMatterRules([]config.FrontMatterRule{{Path: "**", Params: map[string]interface{}{"bad": "{{ .Title"}}}); err == nil {
+		t.Error("NewFrontMatterRules() with a bad template succeeded, want an error")
+	}
+}
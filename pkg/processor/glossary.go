@@ -0,0 +1,427 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/converter"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// Glossary modes.
+const (
+	GlossaryPage   = "page"   // Every term on a glossary page of its own
+	GlossaryInline = "inline" // Terms kept in the documents defining them
+)
+
+// glossaryTitle is the title of the generated glossary page.
+const glossaryTitle = "Glossary"
+
+// glossaryMarker stands for the collected entries in the document hosting
+// them until WritePage replaces it.
+const glossaryMarker = "<!-- rst2md-glossary -->"
+
+var (
+	glossaryDirectiveRegex = regexp.MustCompile(`^(\s*)\.\.\s+glossary::`)
+	hyperlinkRefRegex      = regexp.MustCompile("`((?:[^`\\\\]|\\\\.)+)`(__?)|\\b([A-Za-z0-9]+(?:[-_.+][A-Za-z0-9]+)*)(__?)(?:\\W|$)")
+	footnoteRefRSTRegex    = regexp.MustCompile(`\[([^\]\s]+)\]_`)
+	footnoteDefRSTRegex    = regexp.MustCompile(`^(\s*)\.\.\s+\[([^\]\s]+)\](\s|$)`)
+)
+
+// glossaryEntry is a glossary entry: one or more terms sharing a definition.
+type glossaryEntry struct {
+	Terms      []string
+	Definition []string // reStructuredText, indented by three spaces
+	DocName    string   // Document defining the entry
+}
+
+// Glossary rewrites glossary directives so that every term gets an anchor
+// :term: roles link to, and in page mode collects the entries of every
+// document onto a single page: that of the document hosting the glossary, or
+// a page of their own.
+type Glossary struct {
+	Mode    string
+	DocName string // Document hosting the entries in page mode, or the name of their own page
+	Hosted  bool   // DocName is a document of the project rather than a page of its own
+
+	mu      sync.Mutex
+	entries []glossaryEntry
+	notes   int // Footnotes copied into the definitions so far
+}
+
+// NewGlossary returns the glossary rewriter for mode.
+func NewGlossary(mode string) (*Glossary, error) {
+	switch mode {
+	case "", GlossaryPage:
+		mode = GlossaryPage
+	case GlossaryInline:
+	default:
+		return nil, fmt.Errorf("unknown glossary mode %q: want page or inline", mode)
+	}
+	return &Glossary{Mode: mode}, nil
+}
+
+// termLabel returns the label a glossary term is indexed under, which is
+// also the anchor of the term once made one with utils.HeadingAnchor.
+func termLabel(term string) string {
+	return "term-" + strings.ToLower(term)
+}
+
+// parseGlossary returns the entries of the content of a glossary directive:
+// unindented term lines, each followed by its indented definition.
+func parseGlossary(content []string) []glossaryEntry {
+	var entries []glossaryEntry
+	var entry *glossaryEntry
+	for _, line := range content {
+		switch {
+		case strings.TrimSpace(line) == "":
+			if entry != nil && len(entry.Definition) > 0 {
+				entry.Definition = append(entry.Definition, "")
+			}
+		case line[0] != ' ' && line[0] != '\t':
+			if entry == nil || len(entry.Definition) > 0 {
+				entries = append(entries, glossaryEntry{})
+				entry = &entries[len(entries)-1]
+			}
+			// Sphinx allows a classifier after " : ", which is not part of
+			// the term
+			term, _, _ := strings.Cut(strings.TrimSpace(line), " : ")
+			entry.Terms = append(entry.Terms, term)
+		case entry != nil:
+			entry.Definition = append(entry.Definition, line)
+		}
+	}
+
+	// Definitions are indented by three spaces, keeping the relative
+	// indentation of their lines
+	for i := range entries {
+		definition := dedentLines(trimBlank(entries[i].Definition), "")
+		for j, line := range definition {
+			if line != "" {
+				definition[j] = "   " + line
+			}
+		}
+		entries[i].Definition = definition
+	}
+	return entries
+}
+
+// UseLabels points the term labels of index at the page of the glossary in
+// page mode, if there are any terms. The entries are hosted by the document
+// named glossary defining terms, or else by the only document defining terms
+// if there is one, other than rootDoc. Otherwise they get a page of their
+// own, registered as a document and written to the first of glossary,
+// glossary-1, ... that no document is written to already, as found by
+// outputDir.
+func (g *Glossary) UseLabels(index *LabelIndex, rootDoc string, outputDir func(docName string) string) {
+	if g == nil || g.Mode != GlossaryPage {
+		return
+	}
+	defining := map[string]bool{}
+	for _, label := range index.Labels {
+		if label.Term {
+			defining[label.DocName] = true
+		}
+	}
+	if len(defining) == 0 {
+		return
+	}
+
+	var named []string
+	for docName := range defining {
+		if path.Base(docName) == "glossary" && docName != rootDoc {
+			named = append(named, docName)
+		}
+	}
+	sort.Strings(named)
+	switch {
+	case len(named) > 0:
+		g.DocName, g.Hosted = named[0], true
+	case len(defining) == 1 && !defining[rootDoc]:
+		for docName := range defining {
+			g.DocName, g.Hosted = docName, true
+		}
+	default:
+		taken := map[string]bool{}
+		for docName := range index.Titles {
+			taken[docName] = true
+			taken[outputDir(docName)] = true
+		}
+		g.DocName = "glossary"
+		for i := 1; taken[g.DocName]; i++ {
+			g.DocName = fmt.Sprintf("glossary-%d", i)
+		}
+		if g.DocName != "glossary" {
+			fmt.Fprintf(os.Stderr, "Warning: writing the glossary to %s, since glossary is taken by a document without terms\n", g.DocName)
+		}
+		index.Titles[g.DocName] = glossaryTitle
+	}
+	for name, label := range index.Labels {
+		if label.Term {
+			label.DocName = g.DocName
+			index.Labels[name] = label
+		}
+	}
+}
+
+// MenuEntry returns the menu entry of the glossary page in page mode if it is
+// a page of its own, which no toctree lists.
+func (g *Glossary) MenuEntry() (types.TOCItem, bool) {
+	if g == nil || g.Mode != GlossaryPage || g.DocName == "" || g.Hosted {
+		return types.TOCItem{}, false
+	}
+	return types.TOCItem{ID: g.DocName, Name: glossaryTitle}, true
+}
+
+// Rewrite returns the RST src with its glossary directives replaced by their
+// entries, each preceded by an anchor for every term, or in page mode
+// removed and kept for WritePage with the references of their definitions
+// resolved against src. The first directive of the document hosting the
+// glossary is replaced by a marker WritePage puts the entries at.
+func (g *Glossary) Rewrite(docName, src string) string {
+	if g == nil {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+
+	hosted := g.Mode == GlossaryPage && g.Hosted && docName == g.DocName
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		m := glossaryDirectiveRegex.FindStringSubmatch(line)
+		if literal[i] || m == nil {
+			out = append(out, line)
+			continue
+		}
+		end := blockEnd(lines, i, len(m[1]))
+		d := parseDirectiveBlock(lines[i:end], len(m[1]))
+		i = end - 1
+		if d == nil {
+			continue
+		}
+
+		entries := parseGlossary(d.Content)
+		for j := range entries {
+			entries[j].DocName = docName
+		}
+		if g.Mode == GlossaryPage {
+			g.keepReferences(docName, src, entries)
+			g.mu.Lock()
+			g.entries = append(g.entries, entries...)
+			g.mu.Unlock()
+			if hosted {
+				out = append(out, rawMarkdown(m[1], glossaryMarker)...)
+				hosted = false
+			}
+			continue
+		}
+		if _, ok := d.Option("sorted"); ok {
+			sortGlossary(entries)
+		}
+		for _, line := range renderGlossary(entries) {
+			if line == "" {
+				out = append(out, "")
+			} else {
+				out = append(out, m[1]+line)
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// keepReferences rewrites the definitions of entries, which WritePage
+// converts apart from their document src, so that their references keep
+// pointing at the targets and footnotes of docName: hyperlink references
+// become embedded links, those to sections through the source file of
+// docName, and the footnotes referenced are copied into the definition under
+// labels unique to the glossary page.
+func (g *Glossary) keepReferences(docName, src string, entries []glossaryEntry) {
+	targets := rst.Targets(rst.Parse(src))
+	notes := footnoteBlocks(src)
+	for i := range entries {
+		labels := map[string]string{}
+		var appended []string
+		definition := rst.MapText(strings.Join(entries[i].Definition, "\n"), func(text string) string {
+			text = footnoteRefRSTRegex.ReplaceAllStringFunc(text, func(match string) string {
+				label := footnoteRefRSTRegex.FindStringSubmatch(match)[1]
+				block, ok := notes[label]
+				if !ok {
+					return match
+				}
+				if _, ok := labels[label]; !ok {
+					g.mu.Lock()
+					g.notes++
+					labels[label] = fmt.Sprintf("#glossary-%d", g.notes)
+					g.mu.Unlock()
+					appended = append(appended, "", "   .. ["+labels[label]+"]"+block[0])
+					for _, line := range block[1:] {
+						if line != "" {
+							line = "   " + line
+						}
+						appended = append(appended, line)
+					}
+				}
+				return "[" + labels[label] + "]_"
+			})
+			return resolveHyperlinks(text, docName, targets)
+		})
+		entries[i].Definition = append(strings.Split(definition, "\n"), appended...)
+	}
+}
+
+// resolveHyperlinks returns text with its named hyperlink references to
+// targets replaced by anonymous references embedding their URL. Anchors in
+// docName are linked through its source file.
+func resolveHyperlinks(text, docName string, targets map[string]string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range hyperlinkRefRegex.FindAllStringSubmatchIndex(text, -1) {
+		phrase, name, end := "", "", loc[1]
+		switch {
+		case loc[2] >= 0 && text[loc[4]:loc[5]] == "_":
+			phrase = text[loc[2]:loc[3]]
+			if strings.HasSuffix(strings.TrimSpace(phrase), ">") {
+				continue // The URL is embedded already
+			}
+			name = phrase
+		case loc[6] >= 0 && text[loc[8]:loc[9]] == "_":
+			phrase, name, end = text[loc[6]:loc[7]], text[loc[6]:loc[7]], loc[9]
+		default:
+			continue
+		}
+		url, ok := targets[strings.ToLower(strings.Join(strings.Fields(name), " "))]
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(url, "#") {
+			url = "/" + docName + ".rst" + url
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString("`" + phrase + " <" + url + ">`__")
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// footnoteBlocks returns the footnotes and citations defined in src keyed by
+// label, each as the text after its label followed by its dedented lines.
+func footnoteBlocks(src string) map[string][]string {
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+	blocks := map[string][]string{}
+	for i, line := range lines {
+		m := footnoteDefRSTRegex.FindStringSubmatch(line)
+		if m == nil || literal[i] || m[2] == "#" || m[2] == "*" {
+			continue
+		}
+		end := blockEnd(lines, i, len(m[1]))
+		block := []string{line[len(m[0])-len(m[3]):]}
+		for _, line := range lines[i+1 : end] {
+			block = append(block, strings.TrimPrefix(line, m[1]))
+		}
+		blocks[m[2]] = block
+	}
+	return blocks
+}
+
+// sortGlossary sorts entries by their first term, ignoring case.
+func sortGlossary(entries []glossaryEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := strings.ToLower(entries[i].Terms[0]), strings.ToLower(entries[j].Terms[0])
+		if a != b {
+			return a < b
+		}
+		return entries[i].DocName < entries[j].DocName
+	})
+}
+
+// renderGlossary returns the RST of glossary entries: a definition list item
+// for every entry, with the terms joined, preceded by the anchors of its
+// terms.
+func renderGlossary(entries []glossaryEntry) []string {
+	var out []string
+	for _, entry := range entries {
+		var anchors []string
+		for _, term := range entry.Terms {
+			anchors = append(anchors, fmt.Sprintf("<a id=\"%s\"></a>", utils.HeadingAnchor(termLabel(term))))
+		}
+		out = append(out, rawMarkdown("", strings.Join(anchors, ""))...)
+		out = append(out, strings.Join(entry.Terms, ", "))
+		out = append(out, entry.Definition...)
+		out = append(out, "")
+	}
+	return out
+}
+
+// WritePage converts the entries collected in page mode, sorted by term, and
+// writes them in outputDir: in place of the marker in the pages of the
+// document hosting them, or to a page of their own.
+func (g *Glossary) WritePage(ctx context.Context, conv converter.Converter, project *Project, outputDir string) error {
+	if g == nil || g.Mode != GlossaryPage || g.DocName == "" {
+		return nil
+	}
+	g.mu.Lock()
+	entries := append([]glossaryEntry(nil), g.entries...)
+	g.mu.Unlock()
+	sortGlossary(entries)
+
+	var markdown bytes.Buffer
+	src := strings.Join(renderGlossary(entries), "\n")
+	if err := conv.Convert(ctx, strings.NewReader(src), &markdown); err != nil {
+		return fmt.Errorf("error converting the glossary: %w", err)
+	}
+	body := project.Postprocess(g.DocName, markdown.String())
+
+	dir := filepath.Join(outputDir, filepath.FromSlash(project.OutputDir(g.DocName)))
+	if g.Hosted {
+		return insertGlossary(dir, body)
+	}
+	if err := os.MkdirAll(dir, config.DirPermission); err != nil {
+		return fmt.Errorf("failed to create glossary directory: %w", err)
+	}
+	page := types.PageInfo{Title: glossaryTitle, DocTitle: glossaryTitle, DocName: g.DocName, Path: g.DocName + "/_index.md"}
+	if err := project.Pages.WritePage(filepath.Join(dir, "_index.md"), types.FrontMatter{Title: glossaryTitle}, page, "\n"+body); err != nil {
+		return fmt.Errorf("failed to write the glossary: %w", err)
+	}
+	return nil
+}
+
+// insertGlossary replaces the glossary marker in the pages in dir with the
+// Markdown of the entries.
+func insertGlossary(dir, body string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(content), "\n")
+		for i, line := range lines {
+			if strings.TrimSpace(line) != glossaryMarker {
+				continue
+			}
+			lines[i] = strings.TrimRight(body, "\n")
+			if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), config.FilePermission); err != nil {
+				return fmt.Errorf("failed to write the glossary: %w", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to write the glossary: no glossary marker in the pages in %s", dir)
+}
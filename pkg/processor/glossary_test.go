@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestGlossaryRewrite(t *testing.T) {
+	src := "Terms:\n\n.. glossary::\n   :sorted:\n\n   SDK\n   Kit\n      Software development kit.\n\n      - a list\n\n   API : interface\n      Application programming interface.\n\nAfter.\n"
+
+	inline, err := NewGlossary(GlossaryInline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Terms:\n\n.. raw:: html\n\n   <a id=\"term-api\"></a>\n\nAPI\n   Application programming interface.\n\n" +
+		".. raw:: html\n\n   <a id=\"term-sdk\"></a><a id=\"term-kit\"></a>\n\nSDK, Kit\n   Software development kit.\n\n   - a list\n\n\nAfter.\n"
+	if got := inline.Rewrite("guide", src); got != want {
+		t.Errorf("Rewrite() =\n%q\nwant\n%q", got, want)
+	}
+
+	page, err := NewGlossary("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := page.Rewrite("guide", src), "Terms:\n\n\nAfter.\n"; got != want {
+		t.Errorf("Rewrite() = %q, want %q", got, want)
+	}
+	if len(page.entries) != 2 || page.entries[0].Terms[0] != "SDK" || page.entries[1].DocName != "guide" {
+		t.Errorf("Rewrite() collected %+v", page.entries)
+	}
+
+	// Definitions keep the targets and footnotes of their document
+	src = "Usage\n=====\n\n.. glossary::\n\n   Python\n      See `Python`_, Usage_, `the docs <https://docs.python.org>`_ and [1]_.\n\n" +
+		".. _Python: https://python.org\n\n.. [1] A footnote\n   on two lines.\n"
+	page, err = NewGlossary(GlossaryPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.Rewrite("api/guide", src)
+	wantDefinition := []string{
+		"   See `Python <https://python.org>`__, `Usage </api/guide.rst#usage>`__, `the docs <https://docs.python.org>`_ and [#glossary-1]_.",
+		"",
+		"   .. [#glossary-1] A footnote",
+		"      on two lines.",
+	}
+	if len(page.entries) != 1 || !reflect.DeepEqual(page.entries[0].Definition, wantDefinition) {
+		t.Errorf("Rewrite() collected %q, want %q", page.entries, wantDefinition)
+	}
+}
+
+func TestGlossaryUseLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		docs    map[string]string
+		docName string
+		hosted  bool
+	}{
+		{
+			name:    "glossary document",
+			docs:    map[string]string{"glossary": ".. glossary::\n\n   SDK\n      Kit.\n", "guide": ".. glossary::\n\n   API\n      Interface.\n"},
+			docName: "glossary",
+			hosted:  true,
+		},
+		{
+			name:    "only defining document",
+			docs:    map[string]string{"terms": ".. glossary::\n\n   SDK\n      Kit.\n", "guide": "Guide\n=====\n"},
+			docName: "terms",
+			hosted:  true,
+		},
+		{
+			name:    "page of its own",
+			docs:    map[string]string{"index": ".. glossary::\n\n   SDK\n      Kit.\n", "glossary": "Glossary\n========\n"},
+			docName: "glossary-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := &LabelIndex{Labels: map[string]types.Label{}, Titles: map[string]string{}}
+			for docName, src := range tt.docs {
+				ix.AddDocument(docName, src)
+			}
+			g, err := NewGlossary(GlossaryPage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g.UseLabels(ix, "index", func(docName string) string { return docName })
+			if g.DocName != tt.docName || g.Hosted != tt.hosted {
+				t.Errorf("DocName, Hosted = %q, %v, want %q, %v", g.DocName, g.Hosted, tt.docName, tt.hosted)
+			}
+			if label := ix.Labels["term-sdk"]; label.DocName != tt.docName || !label.Term {
+				t.Errorf("term label = %+v, want it on %s", label, tt.docName)
+			}
+			if _, ok := g.MenuEntry(); ok == tt.hosted {
+				t.Errorf("MenuEntry() ok = %v, want %v", ok, !tt.hosted)
+			}
+			if !tt.hosted && ix.Titles[tt.docName] != "Glossary" {
+				t.Errorf("glossary page is not indexed: %v", ix.Titles)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// Image placement modes.
+const (
+	ImagesShared = "shared" // One copy of every image, laid out as in the input directory
+	ImagesBundle = "bundle" // A copy in the page bundle of every document using the image
+)
+
+// assetScheme prefixes image paths until the pages they end up in are known;
+// see ResolveLinks.
+const assetScheme = "rst2md-asset:"
+
+var (
+	imageDirectiveRegex    = regexp.MustCompile(`^(\s*)\.\.\s+(image|figure)::`)
+	imageSubstitutionRegex = regexp.MustCompile(`^(\s*\.\.\s+\|[^|]+\|\s+image::\s*)(\S+)\s*$`)
+	assetPlaceholderRegex  = regexp.MustCompile(regexp.QuoteMeta(assetScheme) + `([^"'\s)>]+)`)
+)
+
+// imageExtensions are the candidates for an image path ending in .*, in order
+// of preference, as Sphinx picks for HTML.
+var imageExtensions = []string{".svg", ".png", ".gif", ".jpg", ".jpeg", ".webp"}
+
+// Images rewrites the image and figure directives of every document, and
+// records the image files they use so that CopyImages can copy them to the
+// output directory.
+type Images struct {
+	Mode     string
+	InputDir string
+
+	mu     sync.Mutex
+	assets map[string]string // Source file keyed by output path relative to the output directory
+}
+
+// NewImages returns the image rewriter for the images in inputDir, placed
+// according to mode.
+func NewImages(mode, inputDir string) (*Images, error) {
+	switch mode {
+	case "", ImagesShared:
+		mode = ImagesShared
+	case ImagesBundle:
+	default:
+		return nil, fmt.Errorf("unknown image mode %q: want shared or bundle", mode)
+	}
+	return &Images{Mode: mode, InputDir: inputDir, assets: map[string]string{}}, nil
+}
+
+// Rewrite returns the RST src of docName, written to outputDir, with its
+// image and figure directives replaced by raw blocks holding a Markdown image
+// or, if it has options Markdown cannot express, a figure shortcode. Image
+// paths, including those of image substitutions, are replaced with
+// placeholders resolved once the page holding them is known.
+func (im *Images) Rewrite(docName, outputDir, src string) string {
+	if im == nil {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if literal[i] {
+			out = append(out, line)
+			continue
+		}
+		if m := imageSubstitutionRegex.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+im.asset(docName, outputDir, m[2]))
+			continue
+		}
+		m := imageDirectiveRegex.FindStringSubmatch(line)
+		if m == nil {
+			out = append(out, line)
+			continue
+		}
+
+		indent := len(m[1])
+		end := blockEnd(lines, i, indent)
+		d := parseDirectiveBlock(lines[i:end], indent)
+		i = end - 1
+		if d == nil {
+			continue
+		}
+		src := im.asset(docName, outputDir, strings.Join(strings.Fields(d.Argument), ""))
+		if m[2] == "image" {
+			out = append(out, rawMarkdown(m[1], renderImage(d, src, ""))...)
+			continue
+		}
+
+		// The first paragraph of a figure is its caption and the rest its
+		// legend, which stays reStructuredText
+		content := d.Content
+		split := 0
+		for split < len(content) && strings.TrimSpace(content[split]) != "" {
+			split++
+		}
+		caption := captionMarkdown(content[:split])
+		out = append(out, rawMarkdown(m[1], renderImage(d, src, caption))...)
+		for _, legend := range trimBlank(content[split:]) {
+			if legend == "" {
+				out = append(out, "")
+			} else {
+				out = append(out, m[1]+legend)
+			}
+		}
+		out = append(out, "")
+	}
+	return strings.Join(out, "\n")
+}
+
+// asset records the image at uri, relative to the document or, if it starts
+// with a slash, to the input directory, and returns its placeholder. URLs
+// are returned unchanged.
+func (im *Images) asset(docName, outputDir, uri string) string {
+	if uri == "" || strings.Contains(uri, "://") || strings.HasPrefix(uri, "data:") {
+		return uri
+	}
+	rel := path.Join(path.Dir(docName), uri)
+	if strings.HasPrefix(uri, "/") {
+		rel = strings.TrimPrefix(path.Clean(uri), "/")
+	}
+	if strings.HasSuffix(rel, ".*") {
+		rel = im.pickCandidate(rel)
+	}
+	if strings.HasPrefix(rel, "../") {
+		fmt.Fprintf(os.Stderr, "Warning: image %s in %s is outside the input directory\n", uri, docName)
+		return uri
+	}
+
+	target := rel
+	if im.Mode == ImagesBundle {
+		// Images below the document's directory keep their place beside it
+		if local := strings.TrimPrefix(rel, path.Dir(docName)+"/"); path.Dir(docName) != "." && local != rel {
+			target = local
+		}
+		target = path.Join(outputDir, target)
+	}
+
+	im.mu.Lock()
+	im.assets[target] = filepath.Join(im.InputDir, filepath.FromSlash(rel))
+	im.mu.Unlock()
+	return assetScheme + target
+}
+
+// pickCandidate returns the file an image path ending in .* stands for.
+func (im *Images) pickCandidate(rel string) string {
+	base := strings.TrimSuffix(rel, ".*")
+	for _, ext := range imageExtensions {
+		if _, err := os.Stat(filepath.Join(im.InputDir, filepath.FromSlash(base+ext))); err == nil {
+			return base + ext
+		}
+	}
+	return rel
+}
+
+// CopyImages copies the images recorded while rewriting the documents to
+// their place in outputDir. Missing images are reported and skipped, and
+// left for the site check to flag.
+func (im *Images) CopyImages(outputDir string) error {
+	if im == nil {
+		return nil
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	targets := make([]string, 0, len(im.assets))
+	for target := range im.assets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		source := im.assets[target]
+		if _, err := os.Stat(source); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: image %s not found\n", source)
+			continue
+		}
+		dst := filepath.Join(outputDir, filepath.FromSlash(target))
+		if err := os.MkdirAll(filepath.Dir(dst), config.DirPermission); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", target, err)
+		}
+		if err := utils.CopyFile(source, dst); err != nil {
+			return fmt.Errorf("failed to copy image %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// captionMarkdown converts the reStructuredText lines of a figure caption to
+// a single line of Markdown.
+func captionMarkdown(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	if err := rst.Render(&b, rst.Parse(strings.Join(lines, "\n"))); err != nil {
+		return strings.Join(lines, " ")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// renderImage renders an image or figure directive with the image at src:
+// a Markdown image if it has only alternative text and a link target, and a
+// figure shortcode otherwise. A name adds an anchor before it.
+func renderImage(d *rst.Directive, src, caption string) string {
+	var parts []string
+	if name, ok := d.Option("name"); ok {
+		parts = append(parts, fmt.Sprintf("<a id=\"%s\"></a>", utils.HeadingAnchor(name)))
+	}
+
+	alt, _ := d.Option("alt")
+	target, _ := d.Option("target")
+	var params [][2]string
+	for _, name := range []string{"width", "height"} {
+		if v, ok := d.Option(name); ok {
+			params = append(params, [2]string{name, strings.TrimSuffix(v, "px")})
+		}
+	}
+	var classes []string
+	if align, ok := d.Option("align"); ok {
+		classes = append(classes, "align-"+align)
+	}
+	for _, name := range []string{"class", "figclass"} {
+		if v, ok := d.Option(name); ok {
+			classes = append(classes, strings.Fields(v)...)
+		}
+	}
+	if len(classes) > 0 {
+		params = append(params, [2]string{"class", strings.Join(classes, " ")})
+	}
+
+	if d.Name == "image" && len(params) == 0 {
+		img := fmt.Sprintf("![%s](%s)", markdownEscapeReg.ReplaceAllString(alt, `\$1`), src)
+		if target != "" {
+			img = fmt.Sprintf("[%s](%s)", img, target)
+		}
+		return strings.Join(append(parts, img), "\n\n")
+	}
+
+	shortcode := fmt.Sprintf("{{< figure src=%q", src)
+	params = append(params, [2]string{"alt", alt}, [2]string{"link", target}, [2]string{"caption", caption})
+	for _, p := range params {
+		if p[1] != "" {
+			shortcode += fmt.Sprintf(" %s=%q", p[0], p[1])
+		}
+	}
+	return strings.Join(append(parts, shortcode+" >}}"), "\n\n")
+}
+
+// relativeAsset returns the path of the asset at target, relative to the
+// output directory, from the page served at pageURL.
+func relativeAsset(pageURL, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(pageURL), filepath.FromSlash("/"+target))
+	if err != nil {
+		return "/" + target
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImagesRewrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"guide/img/shot.png": "png",
+		"diagram.svg":        "svg",
+		"diagram.png":        "png",
+	})
+	src := "Intro.\n\n.. figure:: img/shot.png\n   :width: 300px\n   :align: center\n   :name: Main shot\n\n" +
+		"   The *main* screen.\n\n   Legend.\n\n.. image:: /diagram.*\n   :alt: Diagram\n\n.. |logo| image:: ../logo.png\n"
+
+	tests := []struct {
+		mode   string
+		want   string
+		copied []string
+	}{
+		{
+			mode: ImagesShared,
+			want: "Intro.\n\n.. raw:: html\n\n   <a id=\"main-shot\"></a>\n\n" +
+				"   {{< figure src=\"rst2md-asset:guide/img/shot.png\" width=\"300\" class=\"align-center\" caption=\"The *main* screen.\" >}}\n\n" +
+				"Legend.\n\n\n.. raw:: html\n\n   ![Diagram](rst2md-asset:diagram.svg)\n\n\n.. |logo| image:: rst2md-asset:logo.png\n",
+			copied: []string{"guide/img/shot.png", "diagram.svg"},
+		},
+		{
+			mode: ImagesBundle,
+			want: "Intro.\n\n.. raw:: html\n\n   <a id=\"main-shot\"></a>\n\n" +
+				"   {{< figure src=\"rst2md-asset:guide/install/img/shot.png\" width=\"300\" class=\"align-center\" caption=\"The *main* screen.\" >}}\n\n" +
+				"Legend.\n\n\n.. raw:: html\n\n   ![Diagram](rst2md-asset:guide/install/diagram.svg)\n\n\n.. |logo| image:: rst2md-asset:guide/install/logo.png\n",
+			copied: []string{"guide/install/img/shot.png", "guide/install/diagram.svg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			im, err := NewImages(tt.mode, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := im.Rewrite("guide/install", "guide/install", src); got != tt.want {
+				t.Errorf("Rewrite() =\n%q\nwant\n%q", got, tt.want)
+			}
+
+			outputDir := t.TempDir()
+			if err := im.CopyImages(outputDir); err != nil {
+				t.Fatalf("CopyImages() error = %v", err)
+			}
+			for _, path := range tt.copied {
+				if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(path))); err != nil {
+					t.Errorf("CopyImages() did not copy %s", path)
+				}
+			}
+		})
+	}
+
+	if _, err := NewImages("bogus", dir); err == nil {
+		t.Error("NewImages() accepted an unknown mode")
+	}
+}
+
+func TestRelativeAsset(t *testing.T) {
+	tests := []struct{ pageURL, target, want string }{
+		{"/guide/", "guide/img/shot.png", "img/shot.png"},
+		{"/guide/usage/", "guide/img/shot.png", "../img/shot.png"},
+		{"/guide/usage/", "_static/logo.png", "../../_static/logo.png"},
+	}
+	for _, tt := range tests {
+		if got := relativeAsset(tt.pageURL, tt.target); got != tt.want {
+			t.Errorf("relativeAsset(%q, %q) = %q, want %q", tt.pageURL, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+)
+
+var (
+	includeRegex       = regexp.MustCompile(`^(\s*)\.\.\s+(include|literalinclude)::\s*(.*?)\s*$`)
+	includeOptionRegex = regexp.MustCompile(`^:([^:]+):\s*(.*)$`)
+)
+
+// codeBlockOptions are the literalinclude options carried over to the code
+// block replacing it.
+var codeBlockOptions = []string{"linenos", "lineno-start", "emphasize-lines", "caption", "name", "class", "force"}
+
+// ExpandIncludes replaces the include and literalinclude directives in src,
+// the source of the file at path, with the content they refer to. Paths are
+// relative to the including file, or to root if they start with a slash.
+// Included files are expanded recursively; an include cycle is an error.
+// Missing files are reported and their directives dropped.
+func ExpandIncludes(root, path, src string) (string, error) {
+	return expandIncludes(root, path, src, []string{path})
+}
+
+func expandIncludes(root, path, src string, stack []string) (string, error) {
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		m := includeRegex.FindStringSubmatch(lines[i])
+		if m == nil || literal[i] {
+			out = append(out, lines[i])
+			continue
+		}
+		indent, name, argument := m[1], m[2], m[3]
+
+		// Options follow on lines indented past the directive
+		options := map[string]string{}
+		j := i + 1
+		for ; j < len(lines); j++ {
+			line := lines[j]
+			if strings.TrimSpace(line) == "" || len(line)-len(strings.TrimLeft(line, " \t")) <= len(indent) {
+				break
+			}
+			if om := includeOptionRegex.FindStringSubmatch(strings.TrimSpace(line)); om != nil {
+				options[strings.ToLower(om[1])] = om[2]
+			}
+		}
+
+		// Standard docutils include files such as <isonum.txt> are left alone
+		if strings.HasPrefix(argument, "<") {
+			out = append(out, lines[i:j]...)
+			i = j - 1
+			continue
+		}
+
+		target := includePath(root, path, argument)
+		data, err := os.ReadFile(target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s in %s: %v\n", name, path, err)
+			i = j - 1
+			continue
+		}
+		content := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+		var expanded string
+		if name == "literalinclude" {
+			expanded, err = literalInclude(content, options)
+		} else {
+			expanded, err = include(root, target, content, options, stack)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s %s in %s: %w", name, argument, path, err)
+		}
+		for _, line := range strings.Split(expanded, "\n") {
+			if line == "" {
+				out = append(out, "")
+			} else {
+				out = append(out, indent+line)
+			}
+		}
+		i = j - 1
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// includePath resolves an include argument against the including file.
+func includePath(root, path, argument string) string {
+	if strings.HasPrefix(argument, "/") {
+		return filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(argument, "/")))
+	}
+	return filepath.Join(filepath.Dir(path), filepath.FromSlash(argument))
+}
+
+// include returns the content of an include directive.
+func include(root, target, content string, options map[string]string, stack []string) (string, error) {
+	for _, p := range stack {
+		if p == target {
+			return "", fmt.Errorf("include cycle: %s", strings.Join(append(stack, target), " -> "))
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if v, ok := options["start-line"]; ok {
+		lines = sliceLines(lines, v, true)
+	}
+	if v, ok := options["end-line"]; ok {
+		lines = sliceLines(lines, v, false)
+	}
+	if v, ok := options["start-after"]; ok {
+		lines = textAfter(lines, v)
+	}
+	if v, ok := options["end-before"]; ok {
+		lines = textBefore(lines, v)
+	}
+	text := strings.Join(lines, "\n")
+
+	if lang, ok := options["code"]; ok {
+		return codeBlock(lang, nil, lines), nil
+	}
+	if _, ok := options["literal"]; ok {
+		return "::\n\n" + indentBlock(lines), nil
+	}
+	return expandIncludes(root, target, text, append(stack, target))
+}
+
+// literalInclude turns a literalinclude into a code-block directive holding
+// the selected lines.
+func literalInclude(content string, options map[string]string) (string, error) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if _, ok := options["pyobject"]; ok {
+		fmt.Fprintf(os.Stderr, "Warning: literalinclude :pyobject: is not supported, including the whole file\n")
+	}
+
+	if v, ok := options["start-at"]; ok {
+		lines = textAt(lines, v)
+	} else if v, ok := options["start-after"]; ok {
+		lines = textAfter(lines, v)
+	}
+	if v, ok := options["end-at"]; ok {
+		lines = textUntil(lines, v)
+	} else if v, ok := options["end-before"]; ok {
+		lines = textBefore(lines, v)
+	}
+	if v, ok := options["lines"]; ok {
+		selected, err := selectLines(lines, v)
+		if err != nil {
+			return "", err
+		}
+		lines = selected
+	}
+	if v, ok := options["dedent"]; ok {
+		lines = dedentLines(lines, v)
+	}
+	if v, ok := options["prepend"]; ok {
+		lines = append([]string{v}, lines...)
+	}
+	if v, ok := options["append"]; ok {
+		lines = append(lines, v)
+	}
+
+	var carried []string
+	for _, name := range codeBlockOptions {
+		if v, ok := options[name]; ok {
+			carried = append(carried, strings.TrimSpace(":"+name+": "+v))
+		}
+	}
+	return codeBlock(options["language"], carried, lines), nil
+}
+
+// codeBlock renders a code-block directive.
+func codeBlock(language string, options, lines []string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(".. code-block:: " + language))
+	b.WriteString("\n")
+	for _, o := range options {
+		b.WriteString("   " + o + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(indentBlock(lines))
+	return b.String()
+}
+
+func indentBlock(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			b.WriteString("   " + line)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// sliceLines applies a Python-style start or end index to lines.
+func sliceLines(lines []string, value string, start bool) []string {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return lines
+	}
+	if n < 0 {
+		n += len(lines)
+	}
+	n = max(0, min(n, len(lines)))
+	if start {
+		return lines[n:]
+	}
+	return lines[:n]
+}
+
+// textAfter returns the lines after the first one containing text.
+func textAfter(lines []string, text string) []string {
+	for i, line := range lines {
+		if strings.Contains(line, text) {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// textAt returns the lines from the first one containing text.
+func textAt(lines []string, text string) []string {
+	for i, line := range lines {
+		if strings.Contains(line, text) {
+			return lines[i:]
+		}
+	}
+	return lines
+}
+
+// textBefore returns the lines before the first one containing text.
+func textBefore(lines []string, text string) []string {
+	for i, line := range lines {
+		if strings.Contains(line, text) {
+			return lines[:i]
+		}
+	}
+	return lines
+}
+
+// textUntil returns the lines up to and including the first one containing
+// text.
+func textUntil(lines []string, text string) []string {
+	for i, line := range lines {
+		if strings.Contains(line, text) {
+			return lines[:i+1]
+		}
+	}
+	return lines
+}
+
+// selectLines selects lines by a Sphinx line specification such as
+// "1,3,5-10,20-", counting from 1.
+func selectLines(lines []string, spec string) ([]string, error) {
+	var selected []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil && !(isRange && strings.TrimSpace(from) == "") {
+			return nil, fmt.Errorf("invalid line specification %q", spec)
+		}
+		if start == 0 {
+			start = 1
+		}
+		end := start
+		if isRange {
+			end = len(lines)
+			if strings.TrimSpace(to) != "" {
+				if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+					return nil, fmt.Errorf("invalid line specification %q", spec)
+				}
+			}
+		}
+		for n := start; n <= end && n <= len(lines); n++ {
+			selected = append(selected, lines[n-1])
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("line specification %q selects no lines", spec)
+	}
+	return selected, nil
+}
+
+// dedentLines removes n leading spaces from every line, or the common
+// indentation if value is empty. Negative values are ignored with a warning.
+func dedentLines(lines []string, value string) []string {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil && n < 0 {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring negative :dedent: %d\n", n)
+		return lines
+	}
+	if err != nil {
+		n = -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if indent := len(line) - len(strings.TrimLeft(line, " ")); n < 0 || indent < n {
+				n = indent
+			}
+		}
+		// Only blank lines have nothing to remove
+		n = max(n, 0)
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		out[i] = line[min(n, indent):]
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shared/note.inc":  "Shared *note*.\n\n.. include:: ../snippets/tip.inc\n",
+		"snippets/tip.inc": "A tip.\n",
+		"snippets/cycle1":  ".. include:: cycle2\n",
+		"snippets/cycle2":  ".. include:: /snippets/cycle1\n",
+		"code/example.py":  "import os\n\n\ndef main():\n    # start\n    print(1)\n    print(2)\n    # end\n",
+		"code/partial.txt": "one\ntwo\nthree\nfour\nfive\n",
+		"code/blank.txt":   "\n\n\nx\n",
+		"code/indented.py": "    a = 1\n      b = 2\n",
+	})
+	path := filepath.Join(dir, "guide", "page.rst")
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+		err  string
+	}{
+		{
+			name: "include relative to the including file",
+			src:  "Intro.\n\n.. include:: ../shared/note.inc\n\nEnd.",
+			want: "Intro.\n\nShared *note*.\n\nA tip.\n\nEnd.",
+		},
+		{
+			name: "include relative to the source root, indented",
+			src:  ".. note::\n\n   .. include:: /snippets/tip.inc",
+			want: ".. note::\n\n   A tip.",
+		},
+		{
+			name: "include as literal",
+			src:  ".. include:: /code/partial.txt\n   :literal:\n   :start-line: 3",
+			want: "::\n\n   four\n   five\n",
+		},
+		{
+			name: "literalinclude with markers and dedent",
+			src: ".. literalinclude:: ../code/example.py\n   :language: python\n   :start-after: # start\n" +
+				"   :end-before: # end\n   :dedent:\n   :emphasize-lines: 2",
+			want: ".. code-block:: python\n   :emphasize-lines: 2\n\n   print(1)\n   print(2)\n",
+		},
+		{
+			name: "literalinclude line selection",
+			src:  ".. literalinclude:: /code/partial.txt\n   :lines: 1,3-4,5-",
+			want: ".. code-block::\n\n   one\n   three\n   four\n   five\n",
+		},
+		{
+			name: "dedent of blank lines",
+			src:  ".. literalinclude:: /code/blank.txt\n   :lines: 1-2\n   :dedent:",
+			want: ".. code-block::\n\n\n\n",
+		},
+		{
+			name: "negative dedent is ignored",
+			src:  ".. literalinclude:: /code/indented.py\n   :dedent: -2",
+			want: ".. code-block::\n\n       a = 1\n         b = 2\n",
+		},
+		{
+			name: "directive in a literal block is kept",
+			src:  "Example::\n\n   .. include:: missing.inc\n",
+			want: "Example::\n\n   .. include:: missing.inc\n",
+		},
+		{
+			name: "standard include is kept",
+			src:  ".. include:: <isonum.txt>",
+			want: ".. include:: <isonum.txt>",
+		},
+		{
+			name: "missing file is dropped",
+			src:  "Text.\n\n.. include:: missing.inc\n",
+			want: "Text.\n\n",
+		},
+		{
+			name: "cycle",
+			src:  ".. include:: /snippets/cycle1",
+			err:  "include cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandIncludes(dir, path, tt.src)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ExpandIncludes() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandIncludes() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+var (
+	placeholderLinkRegex = regexp.MustCompile(`\[((?:[^\]\\]|\\.)*)\]\((rst2md-(?:ref|doc):[^)\s]+)\)`)
+	placeholderHTMLRegex = regexp.MustCompile(`<a href="(rst2md-(?:ref|doc):[^"]+)">(.*?)</a>`)
+	anchorLinkRegex      = regexp.MustCompile(`\]\(#([^)\s]+)\)`)
+	fileLinkRegex        = regexp.MustCompile(`(^|[^!])\[((?:[^\]\\]|\\.)*)\]\(([^)\s:#]+\.(?:rst|html|md|txt))(#[^)\s]*)?\)`)
+	markdownHeadingRegex = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	htmlAnchorRegex      = regexp.MustCompile(`<a id="([^"]+)"></a>`)
+	inlineLinkRegex      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	emphasisMarkRegex    = regexp.MustCompile("[*_`]")
+)
+
+// PageIndex records the pages written for every document, so that links can
+// be resolved against the final layout of the site.
+type PageIndex struct {
+	Pages []*types.Page
+	ByDoc map[string][]*types.Page
+}
+
+// IndexPages reads the pages generated for every document of the project.
+func IndexPages(outputDir string, project *Project) (*PageIndex, error) {
+	index := &PageIndex{ByDoc: map[string][]*types.Page{}}
+
+	docNames := make([]string, 0, len(project.Labels.Titles))
+	for docName := range project.Labels.Titles {
+		docNames = append(docNames, docName)
+	}
+	sort.Strings(docNames)
+
+	for _, docName := range docNames {
+		dir := project.OutputDir(docName)
+		entries, err := os.ReadDir(filepath.Join(outputDir, filepath.FromSlash(dir)))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+				continue
+			}
+			page, err := readPage(outputDir, path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			page.DocName = docName
+			index.Pages = append(index.Pages, page)
+			index.ByDoc[docName] = append(index.ByDoc[docName], page)
+		}
+	}
+	return index, nil
+}
+
+// readPage reads the title, weight and anchors of the page at relPath.
+func readPage(outputDir, relPath string) (*types.Page, error) {
+	content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &types.Page{Path: relPath, URL: PageURL(relPath)}
+	format, frontMatter, body := splitFrontMatter(string(content))
+	var meta struct {
+		Title  string `yaml:"title" toml:"title" json:"title"`
+		Weight int    `yaml:"weight" toml:"weight" json:"weight"`
+	}
+	if err := unmarshalFrontMatter(format, frontMatter, &meta); err == nil {
+		page.Title, page.Weight = meta.Title, meta.Weight
+	}
+	page.Anchors = headingAnchors(body)
+	return page, nil
+}
+
+// PageURL returns the site-absolute URL Hugo serves the page at relPath under.
+func PageURL(relPath string) string {
+	dir, file := path.Split(relPath)
+	if file == "_index.md" || file == "index.md" {
+		return "/" + dir
+	}
+	return "/" + dir + strings.TrimSuffix(file, ".md") + "/"
+}
+
+// headingAnchors returns the anchors of the headings and explicit HTML
+// anchors in a Markdown body, ignoring fenced code blocks.
+func headingAnchors(body string) []string {
+	var anchors []string
+	fenced := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		if m := markdownHeadingRegex.FindStringSubmatch(line); m != nil {
+			anchors = append(anchors, utils.HeadingAnchor(headingText(m[2])))
+		}
+		for _, m := range htmlAnchorRegex.FindAllStringSubmatch(line, -1) {
+			anchors = append(anchors, m[1])
+		}
+	}
+	return anchors
+}
+
+// headingText strips Markdown formatting from a heading.
+func headingText(heading string) string {
+	heading = inlineLinkRegex.ReplaceAllString(heading, "$1")
+	return emphasisMarkRegex.ReplaceAllString(heading, "")
+}
+
+// DocURL returns the URL of the main page of docName.
+func (ix *PageIndex) DocURL(docName string) (string, bool) {
+	for _, page := range ix.ByDoc[docName] {
+		if path.Base(page.Path) == "_index.md" {
+			return page.URL, true
+		}
+	}
+	return "", false
+}
+
+// AnchorURL returns the URL of the page of docName holding anchor: the page
+// itself if its title has that anchor, or the page and fragment otherwise.
+func (ix *PageIndex) AnchorURL(docName, anchor string) (string, bool) {
+	for _, page := range ix.ByDoc[docName] {
+		if utils.HeadingAnchor(page.Title) == anchor {
+			return page.URL, true
+		}
+	}
+	for _, page := range ix.ByDoc[docName] {
+		for _, a := range page.Anchors {
+			if a == anchor {
+				return page.URL + "#" + anchor, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ResolveLinks rewrites cross-reference and image placeholders, and outside
+// code blocks links to other source documents and in-document anchor links,
+// in every generated page now that the final page layout is known. Images are
+// linked relative to the page. Cross-references that cannot be resolved are
+// replaced by their text; document links that cannot be resolved are kept.
+// Both are returned.
+func ResolveLinks(outputDir string, project *Project) ([]types.UnresolvedLink, error) {
+	index, err := IndexPages(outputDir, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index generated pages: %w", err)
+	}
+
+	var unresolved []types.UnresolvedLink
+	for _, page := range index.Pages {
+		filePath := filepath.Join(outputDir, filepath.FromSlash(page.Path))
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		updated := placeholderLinkRegex.ReplaceAllStringFunc(string(content), func(match string) string {
+			m := placeholderLinkRegex.FindStringSubmatch(match)
+			if target, ok := index.resolvePlaceholder(project, m[2]); ok {
+				return "[" + m[1] + "](" + target + ")"
+			}
+			unresolved = append(unresolved, types.UnresolvedLink{Page: page.Path, Target: placeholderRole(m[2])})
+			return m[1]
+		})
+
+		// HTML tables hold their cross-references as HTML links
+		updated = placeholderHTMLRegex.ReplaceAllStringFunc(updated, func(match string) string {
+			m := placeholderHTMLRegex.FindStringSubmatch(match)
+			if target, ok := index.resolvePlaceholder(project, m[1]); ok {
+				return "<a href=\"" + target + "\">" + m[2] + "</a>"
+			}
+			unresolved = append(unresolved, types.UnresolvedLink{Page: page.Path, Target: placeholderRole(m[1])})
+			return m[2]
+		})
+
+		updated = assetPlaceholderRegex.ReplaceAllStringFunc(updated, func(match string) string {
+			return relativeAsset(page.URL, assetPlaceholderRegex.FindStringSubmatch(match)[1])
+		})
+
+		// Links in code blocks are left alone
+		updated = forEachText(strings.Split(updated, "\n"), func(line string) string {
+			line = fileLinkRegex.ReplaceAllStringFunc(line, func(match string) string {
+				m := fileLinkRegex.FindStringSubmatch(match)
+				if target, ok := index.resolveFileLink(project, page.DocName, m[3], strings.TrimPrefix(m[4], "#")); ok {
+					return m[1] + "[" + m[2] + "](" + target + ")"
+				}
+				unresolved = append(unresolved, types.UnresolvedLink{Page: page.Path, Target: m[3] + m[4]})
+				return match
+			})
+			return anchorLinkRegex.ReplaceAllStringFunc(line, func(match string) string {
+				anchor := anchorLinkRegex.FindStringSubmatch(match)[1]
+				for _, a := range page.Anchors {
+					if a == anchor {
+						return match
+					}
+				}
+				if target, ok := index.AnchorURL(page.DocName, anchor); ok {
+					return "](" + target + ")"
+				}
+				return match
+			})
+		})
+
+		if !bytes.Equal(content, []byte(updated)) {
+			if err := os.WriteFile(filePath, []byte(updated), config.FilePermission); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", page.Path, err)
+			}
+		}
+	}
+	return unresolved, nil
+}
+
+// resolvePlaceholder returns the URL a cross-reference placeholder stands for.
+func (ix *PageIndex) resolvePlaceholder(project *Project, placeholder string) (string, bool) {
+	switch {
+	case strings.HasPrefix(placeholder, docScheme):
+		docName, err := url.PathUnescape(strings.TrimPrefix(placeholder, docScheme))
+		if err != nil {
+			return "", false
+		}
+		return ix.DocURL(docName)
+	case strings.HasPrefix(placeholder, refScheme):
+		name, err := url.PathUnescape(strings.TrimPrefix(placeholder, refScheme))
+		if err != nil {
+			return "", false
+		}
+		return ix.resolveLabel(project, name)
+	}
+	return "", false
+}
+
+// placeholderRole returns the role a cross-reference placeholder was written as.
+func placeholderRole(placeholder string) string {
+	role, target, _ := strings.Cut(strings.TrimPrefix(placeholder, "rst2md-"), ":")
+	if name, err := url.PathUnescape(target); err == nil {
+		target = name
+	}
+	if term, ok := strings.CutPrefix(target, "term-"); ok && role == "ref" {
+		return ":term:`" + term + "`"
+	}
+	return ":" + role + ":`" + target + "`"
+}
+
+// resolveLabel returns the URL of the page and anchor a label points at.
+func (ix *PageIndex) resolveLabel(project *Project, name string) (string, bool) {
+	label, ok := project.Labels.Labels[strings.ToLower(name)]
+	if !ok {
+		return "", false
+	}
+	if !label.Section {
+		return ix.AnchorURL(label.DocName, utils.HeadingAnchor(name))
+	}
+	if target, ok := ix.AnchorURL(label.DocName, utils.HeadingAnchor(label.Title)); ok {
+		return target, true
+	}
+	if label.Title == project.Labels.Titles[label.DocName] {
+		// The document title, which may have been replaced, as for index.rst
+		return ix.DocURL(label.DocName)
+	}
+	return "", false
+}
+
+// resolveFileLink returns the URL of a link from docName to another source
+// document, such as other.rst#section or ../guide/install.html, now that the
+// document has been split into pages.
+func (ix *PageIndex) resolveFileLink(project *Project, docName, target, fragment string) (string, bool) {
+	target, err := url.PathUnescape(target)
+	if err != nil {
+		return "", false
+	}
+	target = strings.TrimSuffix(target, path.Ext(target))
+	linked := resolveDocName(docName, target)
+	if _, ok := project.Labels.Titles[linked]; !ok {
+		return "", false
+	}
+	if fragment == "" {
+		return ix.DocURL(linked)
+	}
+
+	if link, ok := ix.AnchorURL(linked, fragment); ok {
+		return link, true
+	}
+	// Sphinx writes labels as the id of the element they point at
+	if label, ok := project.Labels.Labels[strings.ToLower(fragment)]; ok && label.DocName == linked {
+		return ix.resolveLabel(project, fragment)
+	}
+	return ix.AnchorURL(linked, utils.HeadingAnchor(fragment))
+}
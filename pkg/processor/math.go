@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// Math styles.
+const (
+	MathDollars   = "dollars"   // $...$ and $$...$$, for KaTeX or MathJax
+	MathShortcode = "shortcode" // A math shortcode provided by the site
+)
+
+// Placeholders math is carried through conversion as, base64-encoded so that
+// neither converter touches the LaTeX.
+const (
+	mathScheme        = "rst2md-math:"
+	displayMathScheme = "rst2md-math-display:"
+)
+
+var (
+	mathRoleRegex        = regexp.MustCompile("(?s):math:`((?:[^`\\\\]|\\\\.)+)`")
+	mathDirectiveRegex   = regexp.MustCompile(`^(\s*)\.\.\s+math::`)
+	mathPlaceholderRegex = regexp.MustCompile("(?:`|<code>)" + regexp.QuoteMeta(mathScheme) + "([A-Za-z0-9_-]*)(?:`|</code>)")
+	displayMathLineRegex = regexp.MustCompile(`(?m)^([ \t>]*)` + regexp.QuoteMeta(displayMathScheme) + `([A-Za-z0-9_-]*)[ \t]*$`)
+	blankLineSplitRegex  = regexp.MustCompile(`\n\s*\n`)
+)
+
+// Math rewrites :math: roles and math directives into placeholders before
+// conversion, which the converters otherwise turn into raw LaTeX of varying
+// shape, and renders them in the configured style once the pages are split.
+type Math struct {
+	Style string
+}
+
+// NewMath returns the math rewriter for style.
+func NewMath(style string) (*Math, error) {
+	switch style {
+	case "", MathDollars:
+		style = MathDollars
+	case MathShortcode:
+	default:
+		return nil, fmt.Errorf("unknown math style %q: want dollars or shortcode", style)
+	}
+	return &Math{Style: style}, nil
+}
+
+// Rewrite returns the RST src with its :math: roles replaced by inline
+// literals and its math directives by raw blocks, both holding placeholders.
+// A :label: or :name: adds an anchor before the equations.
+func (m *Math) Rewrite(src string) string {
+	if m == nil {
+		return src
+	}
+	src = rst.MapText(src, func(text string) string {
+		return mathRoleRegex.ReplaceAllStringFunc(text, func(match string) string {
+			latex := strings.ReplaceAll(mathRoleRegex.FindStringSubmatch(match)[1], "\\`", "`")
+			return "``" + mathScheme + encodeMath(latex) + "``"
+		})
+	})
+
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		match := mathDirectiveRegex.FindStringSubmatch(line)
+		if literal[i] || match == nil {
+			out = append(out, line)
+			continue
+		}
+		end := blockEnd(lines, i, len(match[1]))
+		d := parseDirectiveBlock(lines[i:end], len(match[1]))
+		i = end - 1
+		if d == nil {
+			continue
+		}
+
+		var parts []string
+		for _, option := range []string{"label", "name"} {
+			if name, ok := d.Option(option); ok {
+				parts = append(parts, fmt.Sprintf("<a id=\"%s\"></a>", utils.HeadingAnchor(name)))
+			}
+		}
+		// Equations are separated by blank lines unless :nowrap: keeps the
+		// content as a single block
+		latex := strings.TrimSpace(strings.Join(append([]string{d.Argument}, d.Content...), "\n"))
+		equations := []string{latex}
+		if _, nowrap := d.Option("nowrap"); !nowrap {
+			equations = blankLineSplitRegex.Split(latex, -1)
+		}
+		for _, eq := range equations {
+			if eq = strings.TrimSpace(eq); eq != "" {
+				parts = append(parts, displayMathScheme+encodeMath(eq))
+			}
+		}
+		out = append(out, rawMarkdown(match[1], strings.Join(parts, "\n\n"))...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// Render returns the Markdown md with its math placeholders rendered, and
+// whether it holds any math.
+func (m *Math) Render(md string) (string, bool) {
+	if m == nil {
+		return md, false
+	}
+	found := false
+	md = displayMathLineRegex.ReplaceAllStringFunc(md, func(match string) string {
+		sub := displayMathLineRegex.FindStringSubmatch(match)
+		latex, ok := decodeMath(sub[2])
+		if !ok {
+			return match
+		}
+		found = true
+		block := "$$\n" + latex + "\n$$"
+		if m.Style == MathShortcode {
+			block = "{{< math display=\"block\" >}}\n" + latex + "\n{{< /math >}}"
+		}
+		return sub[1] + strings.ReplaceAll(block, "\n", "\n"+sub[1])
+	})
+	md = mathPlaceholderRegex.ReplaceAllStringFunc(md, func(match string) string {
+		latex, ok := decodeMath(mathPlaceholderRegex.FindStringSubmatch(match)[1])
+		if !ok {
+			return match
+		}
+		found = true
+		if m.Style == MathShortcode {
+			return "{{< math >}}" + latex + "{{< /math >}}"
+		}
+		return "$" + latex + "$"
+	})
+	return md, found
+}
+
+// encodeMath encodes LaTeX for a placeholder.
+func encodeMath(latex string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(latex))
+}
+
+// decodeMath decodes the LaTeX of a placeholder.
+func decodeMath(encoded string) (string, bool) {
+	latex, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(latex), true
+}
@@ -0,0 +1,69 @@
+package processor
+
+import "testing"
+
+func TestMath(t *testing.T) {
+	src := "Energy :math:`E = mc^2` and ``:math:`kept```.\n\n.. math::\n   :label: euler\n\n   e^{i\\pi} + 1 = 0\n\n   a_b\n"
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{
+			style: MathDollars,
+			want:  "Energy $E = mc^2$ and ``:math:`kept```.\n\n<a id=\"euler\"></a>\n\n$$\ne^{i\\pi} + 1 = 0\n$$\n\n$$\na_b\n$$\n",
+		},
+		{
+			style: MathShortcode,
+			want: "Energy {{< math >}}E = mc^2{{< /math >}} and ``:math:`kept```.\n\n<a id=\"euler\"></a>\n\n" +
+				"{{< math display=\"block\" >}}\ne^{i\\pi} + 1 = 0\n{{< /math >}}\n\n{{< math display=\"block\" >}}\na_b\n{{< /math >}}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			m, err := NewMath(tt.style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			md := m.Rewrite(src)
+			// Stand in for the converter: inline literals become code spans
+			// and raw blocks are passed through dedented
+			converted := "Energy `" + mathScheme + encodeMath("E = mc^2") + "` and ``:math:`kept```.\n\n" +
+				"<a id=\"euler\"></a>\n\n" + displayMathScheme + encodeMath("e^{i\\pi} + 1 = 0") + "\n\n" +
+				displayMathScheme + encodeMath("a_b") + "\n"
+			wantRST := "Energy ``" + mathScheme + encodeMath("E = mc^2") + "`` and ``:math:`kept```.\n\n" +
+				".. raw:: html\n\n   <a id=\"euler\"></a>\n\n   " + displayMathScheme + encodeMath("e^{i\\pi} + 1 = 0") + "\n\n   " +
+				displayMathScheme + encodeMath("a_b") + "\n\n"
+			if md != wantRST {
+				t.Errorf("Rewrite() =\n%q\nwant\n%q", md, wantRST)
+			}
+
+			got, found := m.Render(converted)
+			if got != tt.want || !found {
+				t.Errorf("Render() = %q, %v, want %q, true", got, found, tt.want)
+			}
+		})
+	}
+
+	if _, found := (&Math{Style: MathDollars}).Render("No math $5 here.\n"); found {
+		t.Error("Render() found math in a page without any")
+	}
+}
+
+func TestMathInGridTable(t *testing.T) {
+	src := "+-----+-----------+\n| a   | b         |\n+=====+===========+\n| sum | :math:`x` |\n+-----+-----------+\n"
+	m, err := NewMath(MathDollars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := NewTables("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tables.Rewrite("guide.rst", mapGridCells(src, m.Rewrite))
+	want := ".. raw:: html\n\n   | a | b |\n   | --- | --- |\n   | sum | `" + mathScheme + encodeMath("x") + "` |\n\n"
+	if got != want {
+		t.Errorf("Rewrite() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+)
+
+// listFields are the document fields whose values are lists, separated by
+// commas or semicolons.
+var listFields = map[string]bool{"tags": true, "keywords": true, "authors": true, "categories": true}
+
+var (
+	listSplitRegex  = regexp.MustCompile(`\s*[,;]\s*`)
+	metaDirectiveRe = regexp.MustCompile(`^(\s*)\.\.\s+meta::`)
+)
+
+// Metadata extracts the document-level field list, before or right after the
+// title, and the meta directives of every document, to be written as front
+// matter of the document's main page.
+type Metadata struct {
+	Fields map[string]string // Front matter key keyed by lower-case field name; empty drops the field
+
+	mu   sync.Mutex
+	docs map[string]map[string]interface{}
+}
+
+// NewMetadata returns the metadata extractor, writing fields under the keys
+// fields maps them to and under their lower-case names otherwise.
+func NewMetadata(fields map[string]string) *Metadata {
+	m := &Metadata{Fields: map[string]string{}, docs: map[string]map[string]interface{}{}}
+	for name, key := range fields {
+		m.Fields[strings.ToLower(name)] = key
+	}
+	return m
+}
+
+// Extract returns the RST src of docName without its document fields and
+// meta directives, and records them as the front matter of the document.
+func (m *Metadata) Extract(docName, src string) string {
+	if m == nil {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	params := map[string]interface{}{}
+
+	// The document fields are the first element, or follow the title
+	start := skipBlank(lines, 0)
+	if start < len(lines) && !infoFieldRegex.MatchString(lines[start]) {
+		start = skipBlank(lines, skipTitle(lines, start))
+	}
+	if start < len(lines) && infoFieldRegex.MatchString(lines[start]) {
+		end := start
+		for end < len(lines) && infoFieldRegex.MatchString(lines[end]) {
+			fm := infoFieldRegex.FindStringSubmatch(lines[end])
+			value := []string{fm[2]}
+			end++
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && (lines[end][0] == ' ' || lines[end][0] == '\t') {
+				value = append(value, strings.TrimSpace(lines[end]))
+				end++
+			}
+			m.set(params, fm[1], strings.TrimSpace(strings.Join(value, " ")))
+		}
+		lines = append(lines[:start:start], lines[end:]...)
+	}
+
+	literal := rst.LiteralLines(lines)
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		dm := metaDirectiveRe.FindStringSubmatch(lines[i])
+		if literal[i] || dm == nil {
+			out = append(out, lines[i])
+			continue
+		}
+		end := blockEnd(lines, i, len(dm[1]))
+		if d := parseDirectiveBlock(lines[i:end], len(dm[1])); d != nil {
+			for _, option := range d.Options {
+				m.set(params, option.Name, option.Value)
+			}
+		}
+		i = end - 1
+	}
+
+	if len(params) > 0 {
+		m.mu.Lock()
+		m.docs[docName] = params
+		m.mu.Unlock()
+	}
+	return strings.Join(out, "\n")
+}
+
+// set records the field name with value in params, under the key it maps to.
+func (m *Metadata) set(params map[string]interface{}, name, value string) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	key, ok := m.Fields[name]
+	if !ok {
+		key = name
+	}
+	if key == "" || reservedFrontMatter[key] {
+		return
+	}
+	params[key] = fieldValue(name, value)
+}
+
+// FrontMatter returns the front matter recorded for docName.
+func (m *Metadata) FrontMatter(docName string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.docs[docName]
+}
+
+// fieldValue returns the typed value of the field name: true for an empty
+// field such as :orphan:, a list for the list fields, and a boolean or
+// integer for values that are one.
+func fieldValue(name, value string) interface{} {
+	switch {
+	case value == "":
+		return true
+	case listFields[name]:
+		var items []string
+		for _, item := range listSplitRegex.Split(value, -1) {
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	if b, err := strconv.ParseBool(strings.ToLower(value)); err == nil && (value == "true" || value == "false") {
+		return b
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return value
+}
+
+// skipBlank returns the index of the first line from i that is not blank.
+func skipBlank(lines []string, i int) int {
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	return i
+}
+
+// skipTitle returns the index of the line after the section title starting
+// at i, with or without an overline, or i if there is none.
+func skipTitle(lines []string, i int) int {
+	switch {
+	case i+2 < len(lines) && rst.IsAdornment(lines[i]) && lines[i+2] == lines[i]:
+		return i + 3
+	case i+1 < len(lines) && !rst.IsAdornment(lines[i]) && rst.IsAdornment(lines[i+1]):
+		return i + 2
+	}
+	return i
+}
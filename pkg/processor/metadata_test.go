@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataExtract(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		src    string
+		want   string
+		params map[string]interface{}
+	}{
+		{
+			name:   "after title",
+			src:    "Guide\n=====\n\n:Author: Jane Doe\n:tags: install, setup; cli\n:orphan:\n:nav_order: 3\n:draft: false\n:description: A long\n   description\n\nText :ref:`x`.\n",
+			want:   "Guide\n=====\n\n\nText :ref:`x`.\n",
+			params: map[string]interface{}{"author": "Jane Doe", "tags": []string{"install", "setup", "cli"}, "orphan": true, "nav_order": 3, "draft": false, "description": "A long description"},
+		},
+		{
+			name:   "before title with overline",
+			src:    ":orphan:\n\n=====\nGuide\n=====\n",
+			want:   "\n=====\nGuide\n=====\n",
+			params: map[string]interface{}{"orphan": true},
+		},
+		{
+			name:   "meta directive",
+			src:    "Guide\n=====\n\n.. meta::\n   :keywords: a, b\n   :title: ignored\n\nText.\n\n::\n\n   .. meta::\n      :kept: yes\n",
+			want:   "Guide\n=====\n\n\nText.\n\n::\n\n   .. meta::\n      :kept: yes\n",
+			params: map[string]interface{}{"keywords": []string{"a", "b"}},
+		},
+		{
+			name:   "mapped fields",
+			fields: map[string]string{"Authors": "author", "orphan": ""},
+			src:    ":authors: Jane; John\n:orphan:\n",
+			want:   "",
+			params: map[string]interface{}{"author": []string{"Jane", "John"}},
+		},
+		{
+			name: "field list in the body",
+			src:  "Guide\n=====\n\nText.\n\n:author: Jane\n",
+			want: "Guide\n=====\n\nText.\n\n:author: Jane\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetadata(tt.fields)
+			if got := m.Extract("guide", tt.src); got != tt.want {
+				t.Errorf("Extract() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if got := m.FrontMatter("guide"); !reflect.DeepEqual(got, tt.params) {
+				t.Errorf("FrontMatter() = %#v, want %#v", got, tt.params)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// PageWriter writes the Markdown pages of the site: front matter in Format
+// with the params of the Rules matching the page, followed by the body with
+// its math rendered by Math. Documents are split into pages by the Splitter.
+// The zero value and nil write YAML front matter, split at headings down to
+// level 2 and leave math alone.
+type PageWriter struct {
+	Format   *FrontMatterFormat
+	Rules    *FrontMatterRules
+	Math     *Math
+	Splitter *Splitter
+}
+
+// WriteSections splits the Markdown content into sections and writes them to
+// dirName: the first section to _index.md, with the front matter fm titled
+// by the section, and every following section to its own file. The page
+// describes the document; its path is the directory of its pages. Footnotes
+// are handled per page.
+func (w *PageWriter) WriteSections(dirName, content string, fm types.FrontMatter, page types.PageInfo) error {
+	// Split content into sections with the document's strategy, each with
+	// the footnotes it references
+	var splitter *Splitter
+	if w != nil {
+		splitter = w.Splitter
+	}
+	sections := RehomeFootnotes(splitter.Split(page.DocName, content))
+
+	if len(sections) == 0 {
+		return fmt.Errorf("no sections found in %s", dirName)
+	}
+
+	if err := os.MkdirAll(dirName, config.DirPermission); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dirName, err)
+	}
+	dir := page.Path
+
+	// Create _index.md with front matter
+	fm.Title = sections[0].Title
+	page.Title, page.Path = fm.Title, path.Join(dir, "_index.md")
+	if err := w.WritePage(filepath.Join(dirName, "_index.md"), fm, page, "\n"+sections[0].Content); err != nil {
+		return fmt.Errorf("failed to write _index.md in %s: %w", dirName, err)
+	}
+
+	// Create separate files for each section
+	for i, section := range sections[1:] {
+		fileName := utils.GenerateSlug(section.Title) + ".md"
+		page.Title, page.Path, page.Section = section.Title, path.Join(dir, fileName), i+1
+		sectionFM := types.FrontMatter{Title: section.Title, Weight: (i + 1) * 10}
+		if err := w.WritePage(filepath.Join(dirName, fileName), sectionFM, page, "\n"+section.Content); err != nil {
+			return fmt.Errorf("failed to write %s in %s: %w", fileName, dirName, err)
+		}
+	}
+
+	return nil
+}
+
+// WritePage writes the Markdown page described by page to the file at path:
+// the front matter fm with the params of the rules matching the page,
+// followed by the body with its math rendered. Pages holding math are flagged
+// with math: true so that the site loads the math renderer only where needed.
+func (w *PageWriter) WritePage(path string, fm types.FrontMatter, page types.PageInfo, body string) error {
+	if w == nil {
+		w = &PageWriter{}
+	}
+	body, fm.Math = w.Math.Render(body)
+	params, err := w.Rules.Params(page)
+	if err != nil {
+		return fmt.Errorf("failed to apply the front matter rules to %s: %w", path, err)
+	}
+	// The document's own fields override the rules
+	for key, value := range fm.Params {
+		params[key] = value
+	}
+	fm.Params = params
+
+	frontMatter, err := w.Format.Marshal(fm)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the front matter of %s: %w", path, err)
+	}
+	return os.WriteFile(path, []byte(frontMatter+body), config.FilePermission)
+}
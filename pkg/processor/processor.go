@@ -1,10 +1,16 @@
 package processor
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -18,9 +24,22 @@ import (
 
 // Run orchestrates the main workflow of the application.
 func Run(cfg config.Config) error {
-	// Check for Pandoc
-	if err := converter.CheckPandoc(cfg.PandocPath); err != nil {
-		return fmt.Errorf("pandoc not found: %w", err)
+	ctx := context.Background()
+
+	// Set up the converter backend
+	conv, err := converter.New(cfg)
+	if err != nil {
+		return err
+	}
+	if checker, ok := conv.(converter.Checker); ok {
+		if err := checker.Check(ctx); err != nil {
+			return err
+		}
+	}
+
+	sources, err := NewSources(cfg)
+	if err != nil {
+		return err
 	}
 
 	// Process directories
@@ -28,37 +47,90 @@ func Run(cfg config.Config) error {
 		return err
 	}
 
-	// Process index.rst and parse TOC
-	toc, err := ProcessIndexAndGetTOC(cfg)
+	// Process the root document and parse TOC
+	toc, err := ProcessIndexAndGetTOC(sources)
 	if err != nil {
 		return err
 	}
 
+	// Follow nested toctrees to build the document hierarchy
+	tree, err := BuildSiteTree(sources, toc)
+	if err != nil {
+		return fmt.Errorf("failed to build site tree: %w", err)
+	}
+
+	// Collect labels and titles from every document
+	project, err := NewProject(cfg, sources, tree)
+	if err != nil {
+		return fmt.Errorf("failed to collect cross-reference targets: %w", err)
+	}
+
 	// Process external links
-	if err := ProcessExternalLinks(cfg.OutputDir, toc); err != nil {
+	if err := ProcessExternalLinks(cfg.OutputDir, toc, project.Pages); err != nil {
 		return err
 	}
 
 	// Convert other RST files to Markdown
-	if err := ConvertAllRSTFiles(cfg); err != nil {
+	if err := ConvertAllRSTFiles(ctx, cfg, conv, project); err != nil {
 		return err
 	}
 
-	// Process index.rst separately
-	if err := ProcessIndexRST(cfg); err != nil {
-		return fmt.Errorf("error processing index.rst: %w", err)
+	// Process the root document separately
+	if err := ProcessIndexRST(ctx, cfg, conv, project); err != nil {
+		return fmt.Errorf("error processing %s: %w", sources.RootDoc, err)
 	}
 
-	// Create config.yaml
-	if err := CreateConfigYAML(cfg.OutputDir, toc); err != nil {
+	// Write the glossary page collecting the terms of every document
+	if err := project.Glossary.WritePage(ctx, conv, project, cfg.OutputDir); err != nil {
 		return err
 	}
 
-	// Cleanup intermediate files
-	if err := CleanupIntermediateFiles(cfg.OutputDir); err != nil {
+	// Copy the images the documents use
+	if err := project.Images.CopyImages(cfg.OutputDir); err != nil {
 		return err
 	}
 
+	// Point cross-references and images at the pages their targets ended up in
+	unresolved, err := ResolveLinks(cfg.OutputDir, project)
+	if err != nil {
+		return err
+	}
+	for _, link := range unresolved {
+		fmt.Fprintf(os.Stderr, "Warning: unresolved link %s in %s\n", link.Target, link.Page)
+	}
+
+	// Create config.yaml, with the menu entries below the top-level
+	// documents listing their pages
+	pages, err := IndexPages(cfg.OutputDir, project)
+	if err != nil {
+		return fmt.Errorf("failed to index generated pages: %w", err)
+	}
+	if entry, ok := project.Glossary.MenuEntry(); ok {
+		toc = append(toc, entry)
+	}
+	if err := CreateConfigYAML(cfg, toc, tree, pages); err != nil {
+		return err
+	}
+
+	// Validate the generated site
+	if cfg.Validate {
+		return Validate(cfg.OutputDir, os.Stderr)
+	}
+
+	return nil
+}
+
+// Validate checks the site generated in outputDir, writes a report of any
+// problems to w and returns ErrValidationFailed if there are any.
+func Validate(outputDir string, w io.Writer) error {
+	problems, err := Check(outputDir)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		WriteReport(w, problems)
+		return ErrValidationFailed
+	}
 	return nil
 }
 
@@ -98,22 +170,34 @@ func ProcessDirectories(cfg config.Config) error {
 		}
 	}
 
+	// Copy the contents of the static paths to _static, as Sphinx does
+	for _, staticPath := range cfg.StaticPaths {
+		staticDir := filepath.Join(cfg.InputDir, filepath.FromSlash(staticPath))
+		if _, err := os.Stat(staticDir); err != nil {
+			continue
+		}
+		if err := utils.CopyDir(staticDir, filepath.Join(cfg.OutputDir, "_static")); err != nil {
+			return fmt.Errorf("failed to copy static path %s: %w", staticPath, err)
+		}
+	}
+
 	return nil
 }
 
-// ProcessIndexAndGetTOC processes index.rst and extracts the table of contents.
-func ProcessIndexAndGetTOC(cfg config.Config) ([]types.TOCItem, error) {
-	indexPath := filepath.Join(cfg.InputDir, "index.rst")
+// ProcessIndexAndGetTOC processes the root document, index.rst by default,
+// and extracts the table of contents.
+func ProcessIndexAndGetTOC(sources *Sources) ([]types.TOCItem, error) {
+	indexPath := sources.Path(sources.RootDoc)
 	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("index.rst not found in input directory")
+		return nil, fmt.Errorf("%s not found in input directory", filepath.Base(indexPath))
 	}
 
-	indexContent, err := os.ReadFile(indexPath)
+	indexContent, err := sources.Read(sources.RootDoc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read index.rst: %w", err)
+		return nil, err
 	}
 
-	toc, err := ParseTableOfContents(string(indexContent), cfg.InputDir)
+	toc, err := ParseTableOfContents(indexContent, sources)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse table of contents: %w", err)
 	}
@@ -121,60 +205,6 @@ func ProcessIndexAndGetTOC(cfg config.Config) ([]types.TOCItem, error) {
 	return toc, nil
 }
 
-// ParseTableOfContents parses the toctree in index.rst and returns a slice of TOCItem.
-func ParseTableOfContents(content string, inputDir string) ([]types.TOCItem, error) {
-	var toc []types.TOCItem
-	lines := strings.Split(content, "\n")
-
-	// Find the start of the toctree
-	tocStart := -1
-	for i, line := range lines {
-		if strings.TrimSpace(line) == ".. toctree::" {
-			tocStart = i + 1
-			break
-		}
-	}
-
-	if tocStart == -1 {
-		return nil, fmt.Errorf("table of contents not found in the content")
-	}
-
-	// Process toctree entries
-	for _, line := range lines[tocStart:] {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, ":") {
-			continue
-		}
-
-		if strings.Contains(line, "<") && strings.Contains(line, ">") {
-			// External link
-			parts := strings.SplitN(line, "<", 2)
-			name := strings.TrimSpace(parts[0])
-			url := strings.TrimSuffix(parts[1], ">")
-			toc = append(toc, types.TOCItem{
-				ID:             utils.GenerateSlug(name),
-				Name:           name,
-				IsExternalLink: true,
-				URL:            url,
-			})
-		} else {
-			// Regular file
-			filePath := filepath.Join(inputDir, line+".rst")
-			name, err := GetTopLevelHeading(filePath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get top-level heading for %s: %w", line, err)
-			}
-			toc = append(toc, types.TOCItem{
-				ID:             line,
-				Name:           name,
-				IsExternalLink: false,
-			})
-		}
-	}
-
-	return toc, nil
-}
-
 // GetTopLevelHeading extracts the top-level heading from an RST file.
 func GetTopLevelHeading(filePath string) (string, error) {
 	content, err := os.ReadFile(filePath)
@@ -205,8 +235,9 @@ func GetTopLevelHeading(filePath string) (string, error) {
 	return "", fmt.Errorf("no top-level heading found in %s", filePath)
 }
 
-// ProcessExternalLinks creates directories and _index.md files for external links.
-func ProcessExternalLinks(outputDir string, toc []types.TOCItem) error {
+// ProcessExternalLinks creates directories and _index.md files for external
+// links, written by pages.
+func ProcessExternalLinks(outputDir string, toc []types.TOCItem, pages *PageWriter) error {
 	for _, item := range toc {
 		if item.IsExternalLink {
 			// Create directory for the external link
@@ -215,16 +246,9 @@ func ProcessExternalLinks(outputDir string, toc []types.TOCItem) error {
 				return fmt.Errorf("failed to create directory for %s: %w", item.ID, err)
 			}
 
-			// Create _index.md file content
-			content := fmt.Sprintf(`---
-title: %s
----
-%s
-`, item.Name, item.URL)
-
-			// Write content to _index.md file
+			// Write _index.md file linking to the URL
 			filePath := filepath.Join(dirPath, "_index.md")
-			if err := os.WriteFile(filePath, []byte(content), config.FilePermission); err != nil {
+			if err := pages.WritePage(filePath, types.FrontMatter{Title: item.Name}, types.PageInfo{Title: item.Name, Path: item.ID + "/_index.md"}, item.URL+"\n"); err != nil {
 				return fmt.Errorf("failed to create _index.md for %s: %w", item.ID, err)
 			}
 		}
@@ -232,70 +256,60 @@ title: %s
 	return nil
 }
 
-// ProcessIndexRST processes the index.rst file separately.
-func ProcessIndexRST(cfg config.Config) error {
-	inputPath := filepath.Join(cfg.InputDir, "index.rst")
+// ProcessIndexRST processes the root document, index.rst by default,
+// separately.
+func ProcessIndexRST(ctx context.Context, cfg config.Config, conv converter.Converter, project *Project) error {
+	rootDoc := project.Sources.RootDoc
+	inputPath := project.Sources.Path(rootDoc)
 	overviewDir := filepath.Join(cfg.OutputDir, "overview")
 	if err := os.MkdirAll(overviewDir, config.DirPermission); err != nil {
 		return fmt.Errorf("failed to create overview directory: %w", err)
 	}
 	outputPath := filepath.Join(overviewDir, "_index.md")
 
-	if err := converter.ConvertRSTToMarkdown(inputPath, outputPath, cfg.PandocPath); err != nil {
-		return err
-	}
-
-	// Read the converted markdown file
-	content, err := os.ReadFile(outputPath)
-	if err != nil {
+	var markdown bytes.Buffer
+	if err := convertRSTFile(ctx, conv, project, inputPath, rootDoc, &markdown); err != nil {
 		return err
 	}
+	content := markdown.Bytes()
 
 	// Remove the TOC div
 	tocRe := regexp.MustCompile(`(?s)<div class="toctree".*?</div>`)
 	content = tocRe.ReplaceAll(content, []byte{})
 
+	// The overview is a single page, so split markers are dropped
+	content = []byte(removeSplitMarkers(string(content)))
+
 	// Remove the level 1 heading
 	headingRe := regexp.MustCompile(`(?m)^# .+$`)
 	content = headingRe.ReplaceAll(content, []byte{})
 
-	// Prepare front matter with fixed "Overview" title
-	frontMatter := "---\ntitle: Overview\n---\n\n"
-
-	// Combine front matter and content
-	newContent := []byte(frontMatter + string(content))
-
-	// Write the modified content back to the file
-	if err := os.WriteFile(outputPath, newContent, config.FilePermission); err != nil {
+	// Write the content back with a fixed "Overview" title
+	fm := types.FrontMatter{Title: "Overview", Params: project.Metadata.FrontMatter(rootDoc)}
+	page := project.PageInfo(rootDoc)
+	page.Title, page.Path = fm.Title, "overview/_index.md"
+	if err := project.Pages.WritePage(outputPath, fm, page, "\n"+string(content)); err != nil {
 		return fmt.Errorf("failed to write overview _index.md: %w", err)
 	}
 
 	return nil
 }
 
-// CreateConfigYAML generates the config.yaml file based on the TOC.
-func CreateConfigYAML(outputDir string, toc []types.TOCItem) error {
+// CreateConfigYAML generates the config.yaml file based on the TOC, the site
+// tree, the generated pages and the project metadata.
+func CreateConfigYAML(cfg config.Config, toc []types.TOCItem, tree *types.SiteTree, pages *PageIndex) error {
 	var siteConfig types.SiteConfig
-
-	// Add the Overview section
-	overviewItem := types.MenuItem{
-		Identifier: "overview",
-		Name:       "Overview",
-		URL:        "/overview/",
-		Weight:     10,
-	}
-	siteConfig.Menu.Main = append(siteConfig.Menu.Main, overviewItem)
-
-	// Add the rest of the TOC items
-	for i, entry := range toc {
-		item := types.MenuItem{
-			Identifier: entry.ID,
-			Name:       entry.Name,
-			URL:        "/" + entry.ID + "/",
-			Weight:     (i + 2) * 10, // Start at 20 and increment by 10
+	siteConfig.Title = cfg.Project
+	if cfg.Author != "" || cfg.Version != "" {
+		siteConfig.Params = map[string]string{}
+		if cfg.Author != "" {
+			siteConfig.Params["author"] = cfg.Author
+		}
+		if cfg.Version != "" {
+			siteConfig.Params["version"] = cfg.Version
 		}
-		siteConfig.Menu.Main = append(siteConfig.Menu.Main, item)
 	}
+	siteConfig.Menu.Main = siteMenu(toc, tree, pages)
 
 	// Marshal the config to YAML
 	yamlData, err := yaml.Marshal(&siteConfig)
@@ -304,7 +318,7 @@ func CreateConfigYAML(outputDir string, toc []types.TOCItem) error {
 	}
 
 	// Write the YAML to a file
-	configPath := filepath.Join(outputDir, "config.yaml")
+	configPath := filepath.Join(cfg.OutputDir, "config.yaml")
 	if err := os.WriteFile(configPath, yamlData, config.FilePermission); err != nil {
 		return fmt.Errorf("failed to write config.yaml: %w", err)
 	}
@@ -312,26 +326,125 @@ func CreateConfigYAML(outputDir string, toc []types.TOCItem) error {
 	return nil
 }
 
-// CleanupIntermediateFiles removes temporary files from the output directory.
-func CleanupIntermediateFiles(outputDir string) error {
-	entries, err := os.ReadDir(outputDir)
-	if err != nil {
-		return err
+// siteMenu returns the main menu: the Overview followed by the entries of the
+// root TOC, those of captioned toctrees grouped under a parent entry named
+// after the caption. Entries of hidden toctrees are left out. The :maxdepth:
+// of an entry's toctree lists as many levels of its child documents and,
+// unless :titlesonly:, section pages below it, and :numbered: numbers the
+// names.
+func siteMenu(toc []types.TOCItem, tree *types.SiteTree, pages *PageIndex) []types.MenuItem {
+	// Add the Overview section
+	menu := []types.MenuItem{{
+		Identifier: "overview",
+		Name:       "Overview",
+		URL:        "/overview/",
+		Weight:     10,
+	}}
+
+	// Add the rest of the TOC items
+	weight := 20 // Start at 20 and increment by 10
+	groups := map[string]string{}
+	numbers := map[string]int{} // Last number of the numbered entries of each caption
+	for _, entry := range toc {
+		if entry.IsSelf || entry.Hidden {
+			// index.rst itself is already linked as the Overview
+			continue
+		}
+
+		parent := ""
+		if entry.Group != "" {
+			if parent = groups[entry.Group]; parent == "" {
+				parent = utils.GenerateSlug(entry.Group)
+				groups[entry.Group] = parent
+				menu = append(menu, types.MenuItem{
+					Identifier: parent,
+					Name:       entry.Group,
+					Weight:     weight,
+				})
+				weight += 10
+			}
+		}
+
+		item := types.MenuItem{
+			Identifier: entry.ID,
+			Name:       entry.Name,
+			URL:        "/" + TopLevelOutputDir(entry.ID) + "/",
+			Weight:     weight,
+			Parent:     parent,
+		}
+		if entry.IsExternalLink {
+			item.URL = entry.URL
+		}
+		number := ""
+		if entry.Numbered {
+			numbers[entry.Group]++
+			number = strconv.Itoa(numbers[entry.Group])
+			item.Name = number + ". " + item.Name
+		}
+		menu = append(menu, item)
+		weight += 10
+
+		if tree != nil && !entry.IsExternalLink {
+			menu = append(menu, menuChildren(tree.Nodes[entry.ID], entry, number, entry.MaxDepth-1, pages)...)
+		}
 	}
+	return menu
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") && entry.Name() != "_index.md" {
-			if err := os.Remove(filepath.Join(outputDir, entry.Name())); err != nil {
-				return fmt.Errorf("failed to remove intermediate file %s: %w", entry.Name(), err)
+// menuChildren returns depth levels of menu entries below the entry of node,
+// numbered after number if the toctree entry is numbered: the section pages
+// of the document unless the entry is titles only, followed by its child
+// documents.
+func menuChildren(node *types.DocNode, entry types.TOCItem, number string, depth int, pages *PageIndex) []types.MenuItem {
+	if node == nil || depth <= 0 {
+		return nil
+	}
+	type child struct {
+		item types.MenuItem
+		node *types.DocNode
+	}
+	var children []child
+	if !entry.TitlesOnly && pages != nil {
+		for _, page := range pages.ByDoc[node.DocName] {
+			if path.Base(page.Path) != "_index.md" {
+				children = append(children, child{item: types.MenuItem{Identifier: page.Path, Name: page.Title, URL: page.URL, Weight: page.Weight}})
 			}
 		}
 	}
+	for _, doc := range node.Children {
+		url := "/" + doc.OutputDir + "/"
+		if pages != nil {
+			if docURL, ok := pages.DocURL(doc.DocName); ok {
+				url = docURL
+			}
+		}
+		children = append(children, child{item: types.MenuItem{Identifier: doc.DocName, Name: doc.Title, URL: url, Weight: doc.Weight}, node: doc})
+	}
+	sort.SliceStable(children, func(i, j int) bool { return children[i].item.Weight < children[j].item.Weight })
 
-	return nil
+	var menu []types.MenuItem
+	for i, c := range children {
+		c.item.Parent = node.DocName
+		childNumber := ""
+		if entry.Numbered {
+			childNumber = number + "." + strconv.Itoa(i+1)
+			c.item.Name = childNumber + ". " + c.item.Name
+		}
+		menu = append(menu, c.item)
+		menu = append(menu, menuChildren(c.node, entry, childNumber, depth-1, pages)...)
+	}
+	return menu
 }
 
 // ConvertAllRSTFiles converts all RST files in the input directory to Markdown.
-func ConvertAllRSTFiles(cfg config.Config) error {
+// Documents in the site tree are written to their place in the hierarchy;
+// documents outside it keep their path relative to the input directory.
+func ConvertAllRSTFiles(ctx context.Context, cfg config.Config, conv converter.Converter, project *Project) error {
+	docNames, err := project.Sources.List()
+	if err != nil {
+		return err
+	}
+
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, cfg.MaxParallel)
 
@@ -340,58 +453,42 @@ func ConvertAllRSTFiles(cfg config.Config) error {
 
 	go func() {
 		defer close(done)
-		err := filepath.Walk(cfg.InputDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
+		for _, docName := range docNames {
+			// Skip processing the root document
+			if docName == project.Sources.RootDoc {
+				continue
 			}
 
-			if info.IsDir() {
-				// Exclude certain directories like images
-				if info.Name() == "images" {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			if filepath.Ext(path) == ".rst" {
-				relPath, err := filepath.Rel(cfg.InputDir, path)
-				if err != nil {
-					return err
-				}
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(docName string) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
 
-				// Skip processing index.rst
-				if relPath == "index.rst" {
-					return nil
+				outputDir := filepath.Join(cfg.OutputDir, filepath.FromSlash(project.OutputDir(docName)))
+				weight := 0
+				if node := project.Tree.Nodes[docName]; node != nil {
+					weight = node.Weight
 				}
 
-				wg.Add(1)
-				semaphore <- struct{}{}
-				go func(path, relPath string) {
-					defer wg.Done()
-					defer func() { <-semaphore }()
-
-					outputPath := filepath.Join(cfg.OutputDir, strings.TrimSuffix(relPath, ".rst")+".md")
-					if err := os.MkdirAll(filepath.Dir(outputPath), config.DirPermission); err != nil {
-						errChan <- fmt.Errorf("failed to create directory for %s: %w", outputPath, err)
-						return
-					}
-
-					if err := converter.ConvertRSTToMarkdown(path, outputPath, cfg.PandocPath); err != nil {
-						errChan <- err
-						return
+				var markdown bytes.Buffer
+				if err := convertRSTFile(ctx, conv, project, project.Sources.Path(docName), docName, &markdown); err != nil {
+					select {
+					case errChan <- err:
+					default:
 					}
+					return
+				}
 
-					if err := PostProcessMarkdown(outputPath, cfg.Depth); err != nil {
-						errChan <- err
-						return
+				fm := types.FrontMatter{Weight: weight, Params: project.Metadata.FrontMatter(docName)}
+				if err := project.Pages.WriteSections(outputDir, markdown.String(), fm, project.PageInfo(docName)); err != nil {
+					select {
+					case errChan <- err:
+					default:
 					}
-				}(path, relPath)
-			}
-
-			return nil
-		})
-		if err != nil {
-			errChan <- err
+					return
+				}
+			}(docName)
 		}
 		wg.Wait()
 	}()
@@ -404,41 +501,26 @@ func ConvertAllRSTFiles(cfg config.Config) error {
 	}
 }
 
-// PostProcessMarkdown splits the Markdown content into sections and creates files accordingly.
-func PostProcessMarkdown(filePath string, maxDepth int) error {
-	content, err := os.ReadFile(filePath)
+// convertRSTFile preprocesses the RST document docName at path, converts it,
+// postprocesses the Markdown and writes it to w.
+func convertRSTFile(ctx context.Context, conv converter.Converter, project *Project, path, docName string, w io.Writer) error {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
+		return fmt.Errorf("error converting %s: %w", path, err)
 	}
 
-	// Split content into sections based on headers
-	sections := SplitIntoSections(string(content), maxDepth)
-
-	if len(sections) == 0 {
-		return fmt.Errorf("no sections found in %s", filePath)
-	}
-
-	// Create directory for the file
-	dirName := strings.TrimSuffix(filePath, filepath.Ext(filePath))
-	if err := os.MkdirAll(dirName, config.DirPermission); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dirName, err)
+	src, err := ExpandIncludes(project.Sources.Dir, path, string(content))
+	if err != nil {
+		return fmt.Errorf("error converting %s: %w", path, err)
 	}
-
-	// Create _index.md with front matter
-	indexContent := fmt.Sprintf("---\ntitle: %s\n---\n\n%s", sections[0].Title, sections[0].Content)
-	if err := os.WriteFile(filepath.Join(dirName, "_index.md"), []byte(indexContent), config.FilePermission); err != nil {
-		return fmt.Errorf("failed to write _index.md in %s: %w", dirName, err)
+	src = project.Preprocess(docName, src)
+	var markdown bytes.Buffer
+	if err := conv.Convert(ctx, strings.NewReader(src), &markdown); err != nil {
+		return fmt.Errorf("error converting %s: %w", path, err)
 	}
-
-	// Create separate files for each section
-	for i, section := range sections[1:] {
-		fileName := utils.GenerateSlug(section.Title) + ".md"
-		sectionContent := fmt.Sprintf("---\ntitle: %s\nweight: %d\n---\n\n%s", section.Title, (i+1)*10, section.Content)
-		if err := os.WriteFile(filepath.Join(dirName, fileName), []byte(sectionContent), config.FilePermission); err != nil {
-			return fmt.Errorf("failed to write %s in %s: %w", fileName, dirName, err)
-		}
+	if _, err := io.WriteString(w, project.Postprocess(docName, markdown.String())); err != nil {
+		return fmt.Errorf("error converting %s: %w", path, err)
 	}
-
 	return nil
 }
 
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestSiteMenu(t *testing.T) {
+	root := &types.DocNode{DocName: "index", OutputDir: "overview"}
+	guide := &types.DocNode{DocName: "guide", Title: "Guide", OutputDir: "guide", Weight: 10, Parent: root}
+	linux := &types.DocNode{DocName: "linux", Title: "Linux", OutputDir: "guide/linux", Weight: childWeightOffset + 10, Parent: guide}
+	guide.Children = []*types.DocNode{linux}
+	api := &types.DocNode{DocName: "api", Title: "API", OutputDir: "api", Weight: 20, Parent: root}
+	notes := &types.DocNode{DocName: "notes", Title: "Notes", OutputDir: "notes", Weight: 30, Parent: root}
+	tree := &types.SiteTree{Root: root, Nodes: map[string]*types.DocNode{
+		"index": root, "guide": guide, "linux": linux, "api": api, "notes": notes,
+	}}
+	pages := &PageIndex{ByDoc: map[string][]*types.Page{
+		"guide": {
+			{Path: "guide/_index.md", URL: "/guide/", Title: "Guide"},
+			{Path: "guide/install.md", URL: "/guide/install/", Title: "Install", Weight: 10},
+		},
+		"linux": {
+			{Path: "guide/linux/_index.md", URL: "/guide/linux/", Title: "Linux"},
+			{Path: "guide/linux/steps.md", URL: "/guide/linux/steps/", Title: "Steps", Weight: 10},
+		},
+	}}
+	toc := []types.TOCItem{
+		{ID: "index", Name: "Home", IsSelf: true},
+		{ID: "guide", Name: "Guide", Group: "Docs", MaxDepth: 2, Numbered: true},
+		{ID: "api", Name: "API", Group: "Docs", MaxDepth: 3, TitlesOnly: true, Numbered: true},
+		{ID: "notes", Name: "Notes", Hidden: true},
+		{ID: "example.com-x", Name: "https://example.com/x", IsExternalLink: true, URL: "https://example.com/x"},
+	}
+
+	want := []types.MenuItem{
+		{Identifier: "overview", Name: "Overview", URL: "/overview/", Weight: 10},
+		{Identifier: "docs", Name: "Docs", Weight: 20},
+		{Identifier: "guide", Name: "1. Guide", URL: "/guide/", Weight: 30, Parent: "docs"},
+		{Identifier: "guide/install.md", Name: "1.1. Install", URL: "/guide/install/", Weight: 10, Parent: "guide"},
+		{Identifier: "linux", Name: "1.2. Linux", URL: "/guide/linux/", Weight: childWeightOffset + 10, Parent: "guide"},
+		{Identifier: "api", Name: "2. API", URL: "/api/", Weight: 40, Parent: "docs"},
+		{Identifier: "example.com-x", Name: "https://example.com/x", URL: "https://example.com/x", Weight: 50},
+	}
+	if got := siteMenu(toc, tree, pages); !reflect.DeepEqual(got, want) {
+		t.Errorf("siteMenu() =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/sphinx"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+// Project holds the state collected from the source documents before
+// conversion and shared by every per-document stage.
+type Project struct {
+	Sources *Sources
+	Tree    *types.SiteTree
+	Labels  *LabelIndex
+
+	Prolog string // Text added to the start of every document
+	Epilog string // Text added to the end of every document
+	// Substitutions defined in the prolog and epilog, and so available to
+	// every document
+	Substitutions map[string]*rst.Directive
+	Now           time.Time // Time the date substitution expands to
+
+	Metadata    *Metadata
+	Pages       *PageWriter
+	Admonitions *Admonitions
+	CodeBlocks  *CodeBlocks
+	Images      *Images
+	Tables      *Tables
+	Math        *Math
+	Glossary    *Glossary
+}
+
+// NewProject collects the project-wide state of the source documents.
+func NewProject(cfg config.Config, sources *Sources, tree *types.SiteTree) (*Project, error) {
+	admonitions, err := NewAdmonitions(cfg.Admonitions, cfg.AdmonitionTypes)
+	if err != nil {
+		return nil, err
+	}
+	codeBlocks, err := NewCodeBlocks(cfg.CodeBlocks, cfg.HighlightLanguage)
+	if err != nil {
+		return nil, err
+	}
+	images, err := NewImages(cfg.Images, sources.Dir)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := NewTables(cfg.Tables, sources.Dir)
+	if err != nil {
+		return nil, err
+	}
+	math, err := NewMath(cfg.Math)
+	if err != nil {
+		return nil, err
+	}
+	glossary, err := NewGlossary(cfg.Glossary)
+	if err != nil {
+		return nil, err
+	}
+	frontMatter, err := NewFrontMatterFormat(cfg.FrontMatter)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := NewFrontMatterRules(cfg.FrontMatterRules)
+	if err != nil {
+		return nil, err
+	}
+	splitter, err := NewSplitter(cfg.Split, cfg.Depth, cfg.SplitSize, cfg.SplitRules)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := BuildLabelIndex(sources)
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{
+		Sources:       sources,
+		Tree:          tree,
+		Labels:        labels,
+		Prolog:        cfg.RstProlog,
+		Epilog:        cfg.RstEpilog,
+		Substitutions: rst.SubstitutionDefinitions(cfg.RstProlog + "\n\n" + cfg.RstEpilog),
+		Now:           time.Now(),
+		Metadata:      NewMetadata(cfg.FrontMatterFields),
+		Pages:         &PageWriter{Format: frontMatter, Rules: rules, Math: math, Splitter: splitter},
+		Admonitions:   admonitions,
+		CodeBlocks:    codeBlocks,
+		Images:        images,
+		Tables:        tables,
+		Math:          math,
+		Glossary:      glossary,
+	}
+	glossary.UseLabels(labels, sources.RootDoc, project.OutputDir)
+	return project, nil
+}
+
+// OutputDir returns the output directory of docName relative to the output
+// directory root.
+func (p *Project) OutputDir(docName string) string {
+	if node := p.Tree.Nodes[docName]; node != nil {
+		return node.OutputDir
+	}
+	return docName
+}
+
+// PageInfo returns the description of the pages of docName, without the
+// title, section and file name of a page.
+func (p *Project) PageInfo(docName string) types.PageInfo {
+	page := types.PageInfo{DocName: docName, Path: p.OutputDir(docName)}
+	if p.Labels != nil {
+		page.DocTitle = p.Labels.Titles[docName]
+	}
+	if _, err := os.Stat(p.Sources.Path(docName)); err == nil {
+		if rel, err := filepath.Rel(p.Sources.Dir, p.Sources.Path(docName)); err == nil {
+			page.Source = filepath.ToSlash(rel)
+		}
+	}
+	for node := p.Tree.Nodes[docName]; node != nil && node.Parent != nil; node = node.Parent {
+		for i, sibling := range node.Parent.Children {
+			if sibling == node {
+				page.TOC = append([]int{i + 1}, page.TOC...)
+			}
+		}
+	}
+	return page
+}
+
+// Preprocess applies the source transformations to the RST of docName before
+// it reaches the converter: the document fields and meta directives are taken
+// out as metadata, split directives become markers, the prolog and epilog are
+// added, code blocks, images, figures and domain directives are rendered,
+// substitutions are expanded, math is replaced by placeholders,
+// cross-references are rewritten, and tables and glossaries are rendered.
+func (p *Project) Preprocess(docName, src string) string {
+	src = p.Metadata.Extract(docName, src)
+	src = RewriteSplitMarkers(src)
+	src = sphinx.AppendEpilog(sphinx.PrependProlog(src, p.Prolog), p.Epilog)
+	src = p.CodeBlocks.Rewrite(src)
+	src = p.Images.Rewrite(docName, p.OutputDir(docName), src)
+	src = RewriteDomains(src)
+
+	// Definitions in the document override the global ones
+	defs := map[string]*rst.Directive{}
+	for name, d := range p.Substitutions {
+		defs[name] = d
+	}
+	for name, d := range rst.SubstitutionDefinitions(src) {
+		defs[name] = d
+	}
+	// Substitutions, math placeholders and cross-references change the
+	// width of the text they replace, so grid table columns are resized to
+	// fit them
+	src = mapGridCells(src, func(text string) string {
+		return rst.Substitute(text, defs, p.Now)
+	})
+	src = mapGridCells(src, p.Math.Rewrite)
+	src = mapGridCells(src, func(text string) string {
+		return p.Labels.RewriteReferences(docName, text)
+	})
+
+	// Tables come last so that their cells hold the rewritten references
+	src = p.Tables.Rewrite(p.Sources.Path(docName), src)
+	return p.Glossary.Rewrite(docName, src)
+}
+
+// Postprocess applies the Markdown transformations to the converted
+// docName: admonitions are rewritten in the configured style.
+func (p *Project) Postprocess(docName, md string) string {
+	return p.Admonitions.Rewrite(md)
+}
@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+)
+
+// Sources locates the reStructuredText documents of a project. Documents are
+// named by their slash-separated path relative to Dir without the suffix, as
+// in Sphinx.
+type Sources struct {
+	Dir      string
+	RootDoc  string   // Document holding the root toctree
+	Suffixes []string // Suffixes of source files, in order of preference
+	exclude  []*regexp.Regexp
+}
+
+// NewSources returns the sources described by cfg, defaulting to index as
+// the root document and .rst as the suffix.
+func NewSources(cfg config.Config) (*Sources, error) {
+	s := &Sources{
+		Dir:      cfg.InputDir,
+		RootDoc:  cfg.MasterDoc,
+		Suffixes: cfg.SourceSuffix,
+	}
+	if s.RootDoc == "" {
+		s.RootDoc = "index"
+	}
+	if len(s.Suffixes) == 0 {
+		s.Suffixes = []string{".rst"}
+	}
+	for _, pattern := range cfg.ExcludePatterns {
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		s.exclude = append(s.exclude, re)
+	}
+	return s, nil
+}
+
+// globRegexp translates a Sphinx exclude pattern into a regular expression.
+// As in Sphinx, * and ? do not match slashes while ** matches anything.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// Excluded reports whether the file or directory at the slash-separated path
+// relative to Dir matches an exclude pattern.
+func (s *Sources) Excluded(relPath string) bool {
+	for _, re := range s.exclude {
+		if re.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the path of the source file of docName, or of the file it
+// would have with the preferred suffix if it does not exist.
+func (s *Sources) Path(docName string) string {
+	base := filepath.Join(s.Dir, filepath.FromSlash(docName))
+	for _, suffix := range s.Suffixes {
+		if _, err := os.Stat(base + suffix); err == nil {
+			return base + suffix
+		}
+	}
+	return base + s.Suffixes[0]
+}
+
+// Read returns the source of docName with its includes expanded.
+func (s *Sources) Read(docName string) (string, error) {
+	path := s.Path(docName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", docName, err)
+	}
+	src, err := ExpandIncludes(s.Dir, path, string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", docName, err)
+	}
+	return src, nil
+}
+
+// TrimSuffix removes a source suffix from a document reference.
+func (s *Sources) TrimSuffix(name string) string {
+	for _, suffix := range s.Suffixes {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name {
+			return trimmed
+		}
+	}
+	return name
+}
+
+// docName returns the document name of the file at the slash-separated path
+// relative to Dir, and whether it is a source file.
+func (s *Sources) docName(relPath string) (string, bool) {
+	for _, suffix := range s.Suffixes {
+		if strings.HasSuffix(relPath, suffix) {
+			return strings.TrimSuffix(relPath, suffix), true
+		}
+	}
+	return "", false
+}
+
+// List returns the names of all documents, sorted. Excluded files and
+// directories and the images directory are skipped.
+func (s *Sources) List() ([]string, error) {
+	var docNames []string
+	err := filepath.Walk(s.Dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(s.Dir, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return nil
+		}
+		if info.IsDir() {
+			// Exclude certain directories like images
+			if info.Name() == "images" || s.Excluded(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if docName, ok := s.docName(relPath); ok && !s.Excluded(relPath) {
+			docNames = append(docNames, docName)
+		}
+		return nil
+	})
+	sort.Strings(docNames)
+	return docNames, err
+}
+
+// Glob returns the documents matching a toctree glob pattern, sorted.
+func (s *Sources) Glob(pattern string) ([]string, error) {
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid toctree glob %q: %w", pattern, err)
+	}
+	docNames, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, docName := range docNames {
+		if re.MatchString(docName) {
+			matches = append(matches, docName)
+		}
+	}
+	return matches, nil
+}
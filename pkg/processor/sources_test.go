@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+)
+
+// testSources returns the sources of dir with the default settings.
+func testSources(t *testing.T, dir string) *Sources {
+	t.Helper()
+	sources, err := NewSources(config.Config{InputDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sources
+}
+
+func TestSourcesList(t *testing.T) {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"contents":        "Contents",
+		"guide/install":   "Installing",
+		"drafts/idea":     "Idea",
+		"_build/html/out": "Built",
+	})
+	writeFiles(t, dir, map[string]string{
+		"notes.txt":        "Notes\n=====\n",
+		"guide/_draft.txt": "Draft\n=====\n",
+		"README.md":        "# Readme\n",
+	})
+
+	sources, err := NewSources(config.Config{
+		InputDir:        dir,
+		MasterDoc:       "contents",
+		SourceSuffix:    []string{".rst", ".txt"},
+		ExcludePatterns: []string{"_build", "drafts/*", "**/_*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sources.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{"contents", "guide/install", "notes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+	if sources.RootDoc != "contents" {
+		t.Errorf("RootDoc = %q, want contents", sources.RootDoc)
+	}
+	if got := sources.TrimSuffix("notes.txt"); got != "notes" {
+		t.Errorf("TrimSuffix() = %q, want notes", got)
+	}
+}
@@ -0,0 +1,222 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+// Split strategies.
+const (
+	SplitNone    = "none"    // One page per document
+	SplitHeading = "heading" // A page per heading up to the depth
+	SplitSize    = "size"    // A page per heading for documents above the size, one page otherwise
+	SplitMarker  = "marker"  // A page per rst2md-split directive
+)
+
+var (
+	splitDirectiveRegex = regexp.MustCompile(`^\.\.\s+rst2md-split::[ \t]*(.*)$`)
+	splitMarkerRegex    = regexp.MustCompile(`^<!-- rst2md-split(?:: (.*))? -->\s*$`)
+	markdownHeadingRe   = regexp.MustCompile(`^#{1,6}\s`)
+)
+
+// splitRule is a split rule with its glob compiled.
+type splitRule struct {
+	pattern  *regexp.Regexp
+	strategy string
+	depth    int
+	size     int
+}
+
+// Splitter splits the converted documents into pages with the configured
+// strategy, or that of the last split rule matching the document.
+type Splitter struct {
+	Strategy string
+	Depth    int // Deepest heading level starting a page
+	Size     int // Size in bytes above which the size strategy splits
+
+	rules []splitRule
+}
+
+// NewSplitter returns the splitter for strategy, splitting at headings down
+// to depth, with the rules choosing another strategy for some documents.
+func NewSplitter(strategy string, depth, size int, rules []config.SplitRule) (*Splitter, error) {
+	strategy, err := splitStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+	s := &Splitter{Strategy: strategy, Depth: depth, Size: size}
+	for _, rule := range rules {
+		pattern, err := globRegexp(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid split rule path %q: %w", rule.Path, err)
+		}
+		strategy, err := splitStrategy(rule.Split)
+		if err != nil {
+			return nil, fmt.Errorf("invalid split rule for %q: %w", rule.Path, err)
+		}
+		s.rules = append(s.rules, splitRule{pattern: pattern, strategy: strategy, depth: rule.Depth, size: rule.Size})
+	}
+	return s, nil
+}
+
+// splitStrategy validates strategy, defaulting to splitting at headings.
+func splitStrategy(strategy string) (string, error) {
+	switch strategy {
+	case "":
+		return SplitHeading, nil
+	case SplitNone, SplitHeading, SplitSize, SplitMarker:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown split strategy %q: want none, heading, size or marker", strategy)
+}
+
+// Split splits the Markdown content of docName into pages, the first of
+// which is the document's main page. Split markers are removed unless they
+// split the document.
+func (s *Splitter) Split(docName, content string) []types.Section {
+	strategy, depth, size := SplitHeading, 2, 0
+	if s != nil {
+		strategy, depth, size = s.Strategy, s.Depth, s.Size
+		for _, rule := range s.rules {
+			if !rule.pattern.MatchString(docName) {
+				continue
+			}
+			strategy = rule.strategy
+			if rule.depth != 0 {
+				depth = rule.depth
+			}
+			if rule.size != 0 {
+				size = rule.size
+			}
+		}
+	}
+
+	if strategy == SplitMarker {
+		return splitAtMarkers(content)
+	}
+	content = removeSplitMarkers(content)
+	if strategy == SplitNone || strategy == SplitSize && len(content) <= size {
+		return mergeSections(SplitIntoSections(content, 1))
+	}
+	return SplitIntoSections(content, depth)
+}
+
+// mergeSections returns the sections as a single section, the following ones
+// kept as level 1 headings of the first.
+func mergeSections(sections []types.Section) []types.Section {
+	if len(sections) < 2 {
+		return sections
+	}
+	merged := sections[0]
+	for _, section := range sections[1:] {
+		merged.Content += "# " + section.Title + "\n" + section.Content
+	}
+	return []types.Section{merged}
+}
+
+// splitAtMarkers splits content at its split markers. A page is titled by its
+// marker, or by its first heading, which is then removed; a part without
+// either stays with the page before it.
+func splitAtMarkers(content string) []types.Section {
+	var parts []string
+	var titles []string
+	var part []string
+	forEachLine(content, func(line string, fenced bool) {
+		if m := splitMarkerRegex.FindStringSubmatch(line); m != nil && !fenced {
+			parts = append(parts, strings.Join(part, "\n"))
+			titles = append(titles, m[1])
+			part = nil
+			return
+		}
+		part = append(part, line)
+	})
+	parts = append(parts, strings.Join(part, "\n"))
+
+	sections := mergeSections(SplitIntoSections(parts[0], 1))
+	for i, part := range parts[1:] {
+		section := types.Section{Title: titles[i], Content: part + "\n"}
+		if section.Title == "" {
+			section = titledByHeading(part)
+		}
+		if section.Title == "" && len(sections) > 0 {
+			sections[len(sections)-1].Content += part + "\n"
+			continue
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// titledByHeading returns part as a section titled by its first heading, or
+// untitled if it has none.
+func titledByHeading(part string) types.Section {
+	lines := strings.Split(part, "\n")
+	for i, line := range lines {
+		if !markdownHeadingRe.MatchString(line) {
+			continue
+		}
+		if sections := SplitIntoSections(line, 6); len(sections) == 1 {
+			rest := append(lines[:i:i], lines[i+1:]...)
+			return types.Section{Title: sections[0].Title, Content: strings.Join(rest, "\n") + "\n"}
+		}
+	}
+	return types.Section{Content: part + "\n"}
+}
+
+// removeSplitMarkers returns content without its split markers.
+func removeSplitMarkers(content string) string {
+	var out []string
+	forEachLine(content, func(line string, fenced bool) {
+		if fenced || !splitMarkerRegex.MatchString(line) {
+			out = append(out, line)
+		}
+	})
+	return strings.Join(out, "\n")
+}
+
+// forEachLine calls fn with every line of content and whether it is in a
+// fenced code block.
+func forEachLine(content string, fn func(line string, fenced bool)) {
+	fence := ""
+	for _, line := range strings.Split(content, "\n") {
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1] == fence {
+				fence = ""
+			}
+			fn(line, true)
+			continue
+		}
+		fn(line, fence != "")
+	}
+}
+
+// RewriteSplitMarkers returns the RST src with its rst2md-split directives
+// replaced by split markers that survive conversion, carrying the title of
+// the page they start, if given.
+func RewriteSplitMarkers(src string) string {
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+
+	var out []string
+	for i, line := range lines {
+		m := splitDirectiveRegex.FindStringSubmatch(line)
+		if literal[i] || m == nil {
+			out = append(out, line)
+			continue
+		}
+		marker := "<!-- rst2md-split -->"
+		if title := strings.TrimSpace(strings.ReplaceAll(m[1], "--", "-")); title != "" {
+			marker = "<!-- rst2md-split: " + title + " -->"
+		}
+		out = append(out, "")
+		out = append(out, rawMarkdown("", marker)...)
+	}
+	return strings.Join(out, "\n")
+}
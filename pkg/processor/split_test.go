@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestSplitter(t *testing.T) {
+	md := "# Guide\n\nIntro.\n\n## Install\n\nSteps.\n\n<!-- rst2md-split -->\n\n### Linux\n\n```\n<!-- rst2md-split -->\n```\n\n<!-- rst2md-split: Reference -->\n\n## API\n\nCalls.\n"
+
+	tests := []struct {
+		name    string
+		docName string
+		want    []types.Section
+	}{
+		{
+			name:    "heading",
+			docName: "guide",
+			want: []types.Section{
+				{Title: "Guide", Content: "\nIntro.\n\n"},
+				{Title: "Install", Content: "\nSteps.\n\n\n### Linux\n\n```\n<!-- rst2md-split -->\n```\n\n\n"},
+				{Title: "API", Content: "\nCalls.\n\n"},
+			},
+		},
+		{
+			name:    "none",
+			docName: "single/guide",
+			want: []types.Section{
+				{Title: "Guide", Content: "\nIntro.\n\n## Install\n\nSteps.\n\n\n### Linux\n\n```\n<!-- rst2md-split -->\n```\n\n\n## API\n\nCalls.\n\n"},
+			},
+		},
+		{
+			name:    "size below",
+			docName: "small/guide",
+			want: []types.Section{
+				{Title: "Guide", Content: "\nIntro.\n\n## Install\n\nSteps.\n\n\n### Linux\n\n```\n<!-- rst2md-split -->\n```\n\n\n## API\n\nCalls.\n\n"},
+			},
+		},
+		{
+			name:    "size above",
+			docName: "large/guide",
+			want: []types.Section{
+				{Title: "Guide", Content: "\nIntro.\n\n"},
+				{Title: "Install", Content: "\nSteps.\n\n\n"},
+				{Title: "Linux", Content: "\n```\n<!-- rst2md-split -->\n```\n\n\n"},
+				{Title: "API", Content: "\nCalls.\n\n"},
+			},
+		},
+		{
+			name:    "marker",
+			docName: "marked/guide",
+			want: []types.Section{
+				{Title: "Guide", Content: "\nIntro.\n\n## Install\n\nSteps.\n\n"},
+				{Title: "Linux", Content: "\n\n```\n<!-- rst2md-split -->\n```\n\n"},
+				{Title: "Reference", Content: "\n## API\n\nCalls.\n\n"},
+			},
+		},
+	}
+
+	splitter, err := NewSplitter(SplitHeading, 2, 0, []config.SplitRule{
+		{Path: "single/*", Split: SplitNone},
+		{Path: "small/*", Split: SplitSize, Size: 1000},
+		{Path: "large/*", Split: SplitSize, Depth: 3, Size: 10},
+		{Path: "marked/**", Split: SplitMarker},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitter.Split(tt.docName, md); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewSplitter("pages", 2, 0, nil); err == nil {
+		t.Error("NewSplitter(pages) succeeded, want an error")
+	}
+}
+
+func TestRewriteSplitMarkers(t *testing.T) {
+	src := "Intro.\n\n.. rst2md-split::\n\nMore.\n\n.. rst2md-split:: Next -- part\n\n::\n\n   .. rst2md-split::\n"
+	want := "Intro.\n\n\n.. raw:: html\n\n   <!-- rst2md-split -->\n\n\nMore.\n\n\n.. raw:: html\n\n   <!-- rst2md-split: Next - part -->\n\n\n::\n\n   .. rst2md-split::\n"
+	if got := RewriteSplitMarkers(src); got != want {
+		t.Errorf("RewriteSplitMarkers() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,305 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+var (
+	gridTableRegex      = regexp.MustCompile(`^(\s*)\+[-=+]*-[-=+]*\+$`)
+	tableDirectiveRegex = regexp.MustCompile(`^(\s*)\.\.\s+(table|list-table|csv-table)::`)
+)
+
+// Tables rewrites grid tables and table, list-table and csv-table directives,
+// which the converters turn into raw HTML or lose content of, into pipe tables
+// where they can be one and HTML tables or table shortcodes otherwise.
+type Tables struct {
+	Style string // How tables that are not pipe tables are written
+	Root  string // Input directory, which csv-table paths starting with a slash are relative to
+}
+
+// NewTables returns the table rewriter for style, with root as the input
+// directory.
+func NewTables(style, root string) (*Tables, error) {
+	switch style {
+	case "", rst.TableHTML:
+		style = rst.TableHTML
+	case rst.TableShortcode:
+	default:
+		return nil, fmt.Errorf("unknown table style %q: want html or shortcode", style)
+	}
+	return &Tables{Style: style, Root: root}, nil
+}
+
+// gridTableEnd returns the index of the line after the grid table starting
+// at lines[start], indented by indent.
+func gridTableEnd(lines []string, start int, indent string) int {
+	end := start + 1
+	for end < len(lines) && strings.HasPrefix(lines[end], indent) &&
+		(strings.HasPrefix(lines[end][len(indent):], "+") || strings.HasPrefix(lines[end][len(indent):], "|")) {
+		end++
+	}
+	return end
+}
+
+// mapGridCells returns src with fn applied to the text outside its grid
+// tables and to every cell of the grid tables on its own, line by line. The
+// columns are widened to fit what fn makes of their cells, so that rewrites
+// changing the width of the text keep the grid aligned.
+func mapGridCells(src string, fn func(string) string) string {
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+
+	// Tables are set aside behind placeholder lines while fn rewrites the
+	// rest of the document
+	var tables [][]string
+	var rest []string
+	for i := 0; i < len(lines); i++ {
+		m := gridTableRegex.FindStringSubmatch(lines[i])
+		if literal[i] || m == nil {
+			rest = append(rest, lines[i])
+			continue
+		}
+		end := gridTableEnd(lines, i, m[1])
+		rest = append(rest, fmt.Sprintf("%srst2md-grid-table-%d", m[1], len(tables)))
+		tables = append(tables, mapGridTable(lines[i:end], len(m[1]), fn))
+		i = end - 1
+	}
+	if len(tables) == 0 {
+		return fn(src)
+	}
+
+	var out []string
+	for _, line := range strings.Split(fn(strings.Join(rest, "\n")), "\n") {
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), "rst2md-grid-table-%d", &n); err == nil && n < len(tables) {
+			out = append(out, tables[n]...)
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// gridSegment is the text of a line of a grid table between two column
+// boundaries, which are further apart than neighbouring ones where cells
+// span columns.
+type gridSegment struct {
+	from, to int // Column boundaries, indices into the boundaries of the top border
+	text     []rune
+	border   bool // The segment is part of a row separator
+}
+
+// mapGridTable returns the grid table in lines, indented by indent, with fn
+// applied to the text of every cell line and its columns widened to fit.
+func mapGridTable(lines []string, indent int, fn func(string) string) []string {
+	var bounds []int
+	for i, c := range []rune(lines[0][indent:]) {
+		if c == '+' {
+			bounds = append(bounds, i)
+		}
+	}
+	if len(bounds) < 2 {
+		return lines
+	}
+
+	rows := make([][]gridSegment, len(lines))
+	seps := make([][]rune, len(lines))
+	for i, line := range lines {
+		runes := []rune(line[min(indent, len(line)):])
+		at := func(pos int) rune {
+			if pos < len(runes) {
+				return runes[pos]
+			}
+			return ' '
+		}
+		seps[i] = []rune{at(0)}
+		from := 0
+		for k := 1; k < len(bounds); k++ {
+			if c := at(bounds[k]); c != '|' && c != '+' && k < len(bounds)-1 {
+				continue
+			}
+			text := runes[min(bounds[from]+1, len(runes)):min(bounds[k], len(runes))]
+			segment := gridSegment{from: from, to: k, text: text}
+			if trimmed := strings.Trim(string(text), "-="); trimmed == "" && len(text) > 0 {
+				segment.border = true
+			} else {
+				segment.text = []rune(fn(string(text)))
+			}
+			rows[i] = append(rows[i], segment)
+			seps[i] = append(seps[i], at(bounds[k]))
+			from = k
+		}
+	}
+
+	// Widen single columns first, then the last column of spans that are
+	// still too narrow
+	extra := make([]int, len(bounds)-1)
+	width := func(from, to int) int {
+		w := bounds[to] - bounds[from] - 1
+		for k := from; k < to; k++ {
+			w += extra[k]
+		}
+		return w
+	}
+	needed := func(segment gridSegment) int {
+		return len([]rune(strings.TrimRight(string(segment.text), " "))) + 1
+	}
+	for _, spans := range []bool{false, true} {
+		for _, row := range rows {
+			for _, segment := range row {
+				if segment.border || (segment.to-segment.from > 1) != spans {
+					continue
+				}
+				if missing := needed(segment) - width(segment.from, segment.to); missing > 0 {
+					extra[segment.to-1] += missing
+				}
+			}
+		}
+	}
+
+	out := make([]string, len(lines))
+	for i, row := range rows {
+		var b strings.Builder
+		b.WriteString(lines[i][:min(indent, len(lines[i]))])
+		b.WriteRune(seps[i][0])
+		for j, segment := range row {
+			w := width(segment.from, segment.to)
+			text := segment.text
+			pad := " "
+			if segment.border {
+				pad = string(text[0])
+			} else {
+				text = []rune(strings.TrimRight(string(text), " "))
+			}
+			b.WriteString(string(text))
+			b.WriteString(strings.Repeat(pad, max(w-len(text), 0)))
+			b.WriteRune(seps[i][j+1])
+		}
+		out[i] = b.String()
+	}
+	return out
+}
+
+// Rewrite returns the RST src of the file at path with its tables replaced by
+// raw blocks holding their Markdown. Tables that do not parse are left for
+// the converter. References in the cells resolve to the targets of src.
+func (t *Tables) Rewrite(path, src string) string {
+	if t == nil {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+
+	var doc *rst.Document
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if literal[i] {
+			out = append(out, line)
+			continue
+		}
+		if doc == nil && (gridTableRegex.MatchString(line) || tableDirectiveRegex.MatchString(line)) {
+			doc = rst.Parse(src)
+		}
+
+		if m := gridTableRegex.FindStringSubmatch(line); m != nil {
+			end := gridTableEnd(lines, i, m[1])
+			md, ok := t.render(path, doc, nil, lines[i:end], len(m[1]))
+			if !ok {
+				out = append(out, lines[i:end]...)
+			} else {
+				out = append(out, rawMarkdown(m[1], md)...)
+			}
+			i = end - 1
+			continue
+		}
+
+		m := tableDirectiveRegex.FindStringSubmatch(line)
+		if m == nil {
+			out = append(out, line)
+			continue
+		}
+		end := blockEnd(lines, i, len(m[1]))
+		md, ok := t.render(path, doc, parseDirectiveBlock(lines[i:end], len(m[1])), nil, 0)
+		if !ok {
+			out = append(out, lines[i:end]...)
+		} else {
+			out = append(out, rawMarkdown(m[1], md)...)
+		}
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// render returns the Markdown of a table directive of doc, or of the grid
+// table in lines indented by indent if d is nil, preceded by an anchor for
+// its name and its title.
+func (t *Tables) render(path string, doc *rst.Document, d *rst.Directive, lines []string, indent int) (string, bool) {
+	var table *rst.Table
+	if d == nil {
+		table = parseTable(lines, indent)
+	} else {
+		switch strings.ToLower(d.Name) {
+		case "table":
+			table = parseTable(d.Content, 0)
+		case "list-table":
+			table = rst.ListTable(d)
+		case "csv-table":
+			data := strings.Join(d.Content, "\n")
+			if file, ok := d.Option("file"); ok {
+				content, err := os.ReadFile(includePath(t.Root, path, file))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: csv-table in %s: %v\n", path, err)
+					return "", false
+				}
+				data = strings.ReplaceAll(string(content), "\r\n", "\n")
+			} else if url, ok := d.Option("url"); ok {
+				fmt.Fprintf(os.Stderr, "Warning: csv-table in %s: remote data %s is not supported\n", path, url)
+				return "", false
+			}
+			table = rst.CSVTable(d, data)
+		}
+	}
+	if table == nil {
+		return "", false
+	}
+
+	var b bytes.Buffer
+	if err := rst.RenderTable(&b, doc, table, t.Style); err != nil {
+		return "", false
+	}
+	parts := []string{b.String()}
+	if d != nil {
+		if title := strings.TrimSpace(d.Argument); title != "" {
+			parts = append([]string{"*" + markdownEscapeReg.ReplaceAllString(title, `\$1`) + "*"}, parts...)
+		}
+		if name, ok := d.Option("name"); ok {
+			parts = append([]string{fmt.Sprintf("<a id=\"%s\"></a>", utils.HeadingAnchor(name))}, parts...)
+		}
+	}
+	return strings.Join(parts, "\n\n"), true
+}
+
+// parseTable parses lines, indented by indent, holding a grid or simple
+// table.
+func parseTable(lines []string, indent int) *rst.Table {
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent {
+			dedented[i] = line[indent:]
+		}
+	}
+	doc := rst.Parse(strings.Join(dedented, "\n"))
+	if len(doc.Children) != 1 {
+		return nil
+	}
+	table, _ := doc.Children[0].(*rst.Table)
+	return table
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+)
+
+func TestTablesRewrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"guide/data/sizes.csv": "Size,Bytes\nsmall,\"1,024\"\n",
+	})
+	path := filepath.Join(dir, "guide", "install.rst")
+
+	tests := []struct {
+		name  string
+		style string
+		src   string
+		want  string
+	}{
+		{
+			name:  "grid table with spans",
+			style: "html",
+			src:   "Intro.\n\n+-----+-----+\n| A   | B   |\n+=====+=====+\n| both      |\n+-----------+\n\nAfter.\n",
+			want: "Intro.\n\n.. raw:: html\n\n   <table>\n   <thead>\n   <tr>\n   <th>A</th>\n   <th>B</th>\n   </tr>\n   </thead>\n" +
+				"   <tbody>\n   <tr>\n   <td colspan=\"2\">both</td>\n   </tr>\n   </tbody>\n   </table>\n\n\nAfter.\n",
+		},
+		{
+			name:  "shortcode",
+			style: "shortcode",
+			src:   "+---+---+\n| a | b |\n+---+---+\n| c     |\n+-------+\n",
+			want:  ".. raw:: html\n\n   {{< table >}}\n   <table>\n   <tbody>\n   <tr>\n   <td>a</td>\n   <td>b</td>\n   </tr>\n   <tr>\n   <td colspan=\"2\">c</td>\n   </tr>\n   </tbody>\n   </table>\n   {{< /table >}}\n\n",
+		},
+		{
+			name:  "list table",
+			style: "html",
+			src:   ".. list-table:: Flags\n   :header-rows: 1\n   :name: flag-table\n\n   * - Flag\n     - Use\n   * - ``-v``\n     - Verbose\n",
+			want:  ".. raw:: html\n\n   <a id=\"flag-table\"></a>\n\n   *Flags*\n\n   | Flag | Use |\n   | --- | --- |\n   | `-v` | Verbose |\n\n",
+		},
+		{
+			name:  "csv file relative to the document",
+			style: "html",
+			src:   ".. csv-table::\n   :file: data/sizes.csv\n   :header-rows: 1\n",
+			want:  ".. raw:: html\n\n   | Size | Bytes |\n   | --- | --- |\n   | small | 1,024 |\n\n",
+		},
+		{
+			name:  "missing csv file",
+			style: "html",
+			src:   ".. csv-table::\n   :file: missing.csv\n",
+			want:  ".. csv-table::\n   :file: missing.csv\n",
+		},
+		{
+			name:  "references to the document's targets",
+			style: "html",
+			src:   "+------------+\n| `Python`_  |\n+------------+\n| `Usage`_   |\n+------------+\n\n.. _Python: https://python.org\n\nUsage\n=====\n",
+			want:  ".. raw:: html\n\n   |  |\n   | --- |\n   | [Python](https://python.org) |\n   | [Usage](#usage) |\n\n\n.. _Python: https://python.org\n\nUsage\n=====\n",
+		},
+		{
+			name:  "literal block",
+			style: "html",
+			src:   "::\n\n   +---+\n   | a |\n   +---+\n",
+			want:  "::\n\n   +---+\n   | a |\n   +---+\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables, err := NewTables(tt.style, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tables.Rewrite(path, tt.src); got != tt.want {
+				t.Errorf("Rewrite() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewTables("latex", dir); err == nil {
+		t.Error("NewTables() accepted an unknown style")
+	}
+}
+
+func TestMapGridCells(t *testing.T) {
+	upper := func(text string) string { return strings.ReplaceAll(text, "x", "xxxxxx") }
+	src := "x\n\n  +---+-----+\n  | x | a   |\n  +===+=====+\n  | b   x   |\n  +---------+\n\n::\n\n   +---+\n   | x |\n   +---+\n"
+	want := "xxxxxx\n\n  +--------+-----+\n  | xxxxxx | a   |\n  +========+=====+\n  | b   xxxxxx   |\n  +--------------+\n\n::\n\n   +---+\n   | xxxxxx |\n   +---+\n"
+	if got := mapGridCells(src, upper); got != want {
+		t.Errorf("mapGridCells() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSubstituteInGridTable(t *testing.T) {
+	src := ".. |ver| replace:: version 1.2\n\n+---+-------+\n| x | a     |\n+---+-------+\n| y | |ver| |\n+---+-------+\n"
+	defs := rst.SubstitutionDefinitions(src)
+	got := mapGridCells(src, func(text string) string { return rst.Substitute(text, defs, time.Time{}) })
+	want := ".. |ver| replace:: version 1.2\n\n+---+-------------+\n| x | a           |\n+---+-------------+\n| y | version 1.2 |\n+---+-------------+\n"
+	if got != want {
+		t.Errorf("mapGridCells() =\n%q\nwant\n%q", got, want)
+	}
+
+	tables, err := NewTables("html", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md := tables.Rewrite("guide.rst", got); !strings.Contains(md, "| y | version 1.2 |") {
+		t.Errorf("Rewrite() =\n%q\nwant a row with the substitution", md)
+	}
+}
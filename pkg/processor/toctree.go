@@ -0,0 +1,208 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+// ParseTableOfContents parses the toctrees in the root document and returns
+// their entries in order. Entries of captioned toctrees carry the caption as
+// their Group.
+func ParseTableOfContents(content string, sources *Sources) ([]types.TOCItem, error) {
+	trees, err := ParseTocTrees(content, sources.RootDoc, sources)
+	if err != nil {
+		return nil, err
+	}
+	if len(trees) == 0 {
+		return nil, fmt.Errorf("table of contents not found in the content")
+	}
+
+	var toc []types.TOCItem
+	for _, tree := range trees {
+		toc = append(toc, tree.Items...)
+	}
+	return toc, nil
+}
+
+// ParseTocTrees parses every toctree directive in the content of the document
+// docName. Entries are resolved relative to the document's directory, glob
+// patterns are expanded against the sources when the :glob: option is set and
+// :reversed: reverses the entries. Every entry carries the caption and the
+// :hidden:, :maxdepth:, :titlesonly: and :numbered: options of its toctree,
+// which shape its menu entry; hidden entries are still part of the site.
+// The :name: option is ignored.
+func ParseTocTrees(content, docName string, sources *Sources) ([]types.TocTree, error) {
+	var trees []types.TocTree
+	var walkErr error
+	rst.Walk(rst.Parse(content).Children, func(n rst.Node) bool {
+		d, ok := n.(*rst.Directive)
+		if !ok || d.Name != "toctree" || walkErr != nil {
+			return walkErr == nil
+		}
+		tree, err := parseTocTree(d, docName, sources)
+		if err != nil {
+			walkErr = err
+			return false
+		}
+		trees = append(trees, tree)
+		return false
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return trees, nil
+}
+
+func parseTocTree(d *rst.Directive, docName string, sources *Sources) (types.TocTree, error) {
+	tree := types.TocTree{}
+	for _, o := range d.Options {
+		switch o.Name {
+		case "caption":
+			tree.Caption = o.Value
+		case "maxdepth":
+			tree.MaxDepth, _ = strconv.Atoi(o.Value)
+		case "hidden":
+			tree.Hidden = true
+		case "glob":
+			tree.Glob = true
+		case "titlesonly":
+			tree.TitlesOnly = true
+		case "numbered":
+			tree.Numbered = true
+		case "reversed":
+			tree.Reversed = true
+		}
+	}
+
+	for _, line := range d.Content {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "..") {
+			continue
+		}
+
+		title, target, explicit := rst.SplitEmbedded(line)
+		if isURL(target) {
+			// External link; a bare URL is its own title
+			tree.Items = append(tree.Items, types.TOCItem{
+				ID:             externalID(target),
+				Name:           title,
+				IsExternalLink: true,
+				URL:            target,
+			})
+			continue
+		}
+
+		if target == "self" {
+			name := title
+			if !explicit {
+				var err error
+				name, err = GetTopLevelHeading(sources.Path(docName))
+				if err != nil {
+					return tree, fmt.Errorf("failed to get top-level heading for %s: %w", docName, err)
+				}
+			}
+			tree.Items = append(tree.Items, types.TOCItem{ID: docName, Name: name, IsSelf: true})
+			continue
+		}
+
+		if explicit {
+			// Internal document with an overridden title
+			entry := resolveDocName(docName, sources.TrimSuffix(target))
+			if _, err := os.Stat(sources.Path(entry)); err != nil {
+				return tree, fmt.Errorf("toctree entry %s not found: %w", entry, err)
+			}
+			tree.Items = append(tree.Items, types.TOCItem{
+				ID:   entry,
+				Name: title,
+			})
+			continue
+		}
+
+		docNames := []string{resolveDocName(docName, sources.TrimSuffix(line))}
+		if tree.Glob && strings.ContainsAny(line, "*?[") {
+			matches, err := globDocNames(sources, docName, docNames[0])
+			if err != nil {
+				return tree, err
+			}
+			docNames = matches
+		}
+
+		for _, entry := range docNames {
+			// Regular file
+			name, err := GetTopLevelHeading(sources.Path(entry))
+			if err != nil {
+				return tree, fmt.Errorf("failed to get top-level heading for %s: %w", entry, err)
+			}
+			tree.Items = append(tree.Items, types.TOCItem{
+				ID:             entry,
+				Name:           name,
+				IsExternalLink: false,
+			})
+		}
+	}
+
+	if tree.Reversed {
+		for i, j := 0, len(tree.Items)-1; i < j; i, j = i+1, j-1 {
+			tree.Items[i], tree.Items[j] = tree.Items[j], tree.Items[i]
+		}
+	}
+	for i := range tree.Items {
+		item := &tree.Items[i]
+		item.Group, item.Hidden, item.MaxDepth = tree.Caption, tree.Hidden, tree.MaxDepth
+		item.TitlesOnly, item.Numbered = tree.TitlesOnly, tree.Numbered
+	}
+	return tree, nil
+}
+
+// isURL reports whether a toctree entry is a URL rather than a document name.
+func isURL(target string) bool {
+	return strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:")
+}
+
+var externalIDStripRegex = regexp.MustCompile(`[^\p{L}\p{N}._]+`)
+
+// externalID returns the ID of an external toctree entry: the host and path
+// of its URL, without the scheme and with other characters than letters,
+// digits, dots and underscores replaced by hyphens.
+func externalID(target string) string {
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	} else {
+		target = strings.TrimPrefix(target, "mailto:")
+	}
+	return strings.Trim(externalIDStripRegex.ReplaceAllString(strings.ToLower(target), "-"), "-")
+}
+
+// resolveDocName resolves a document reference, such as a toctree entry,
+// against the directory of the document containing it. References starting
+// with a slash are relative to the source root.
+func resolveDocName(docName, entry string) string {
+	if strings.HasPrefix(entry, "/") {
+		return path.Clean(strings.TrimPrefix(entry, "/"))
+	}
+	return path.Clean(path.Join(path.Dir(docName), entry))
+}
+
+// globDocNames returns the documents matching pattern, sorted by name and
+// excluding the document containing the toctree.
+func globDocNames(sources *Sources, docName, pattern string) ([]string, error) {
+	matches, err := sources.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var docNames []string
+	for _, name := range matches {
+		if name != docName {
+			docNames = append(docNames, name)
+		}
+	}
+	return docNames, nil
+}
@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+// writeDocs creates an RST file with a top-level heading for every docname.
+func writeDocs(t *testing.T, dir string, titles map[string]string) {
+	t.Helper()
+	for docName, title := range titles {
+		path := filepath.Join(dir, filepath.FromSlash(docName)+".rst")
+		if err := os.MkdirAll(filepath.Dir(path), config.DirPermission); err != nil {
+			t.Fatal(err)
+		}
+		underline := make([]byte, len(title))
+		for i := range underline {
+			underline[i] = '='
+		}
+		content := title + "\n" + string(underline) + "\n\nText.\n"
+		if err := os.WriteFile(path, []byte(content), config.FilePermission); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeFiles creates files with the given content, keyed by slash-separated
+// path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for relPath, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), config.DirPermission); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), config.FilePermission); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseTocTrees(t *testing.T) {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"index":          "Home",
+		"intro":          "Introduction",
+		"guide/install":  "Installing",
+		"guide/usage":    "Usage",
+		"api/reference":  "Reference",
+		"api/changelog":  "Changelog",
+		"api/deprecated": "Deprecated",
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		want    []types.TocTree
+	}{
+		{
+			name: "stops-at-indentation-boundary",
+			content: `.. toctree::
+   :maxdepth: 2
+
+   intro
+
+guide/install is mentioned here but is not an entry.
+`,
+			want: []types.TocTree{
+				{MaxDepth: 2, Items: []types.TOCItem{{ID: "intro", Name: "Introduction", MaxDepth: 2}}},
+			},
+		},
+		{
+			name: "multiple-captioned-toctrees",
+			content: `.. toctree::
+   :caption: Guide
+   :hidden:
+
+   guide/install
+   guide/usage
+
+.. toctree::
+   :caption: API
+   :titlesonly:
+
+   api/reference
+`,
+			want: []types.TocTree{
+				{Caption: "Guide", Hidden: true, Items: []types.TOCItem{
+					{ID: "guide/install", Name: "Installing", Group: "Guide", Hidden: true},
+					{ID: "guide/usage", Name: "Usage", Group: "Guide", Hidden: true},
+				}},
+				{Caption: "API", TitlesOnly: true, Items: []types.TOCItem{
+					{ID: "api/reference", Name: "Reference", Group: "API", TitlesOnly: true},
+				}},
+			},
+		},
+		{
+			name: "glob-and-self",
+			content: `.. toctree::
+   :glob:
+   :reversed:
+
+   self
+   api/*
+`,
+			want: []types.TocTree{
+				{Glob: true, Reversed: true, Items: []types.TOCItem{
+					{ID: "api/reference", Name: "Reference"},
+					{ID: "api/deprecated", Name: "Deprecated"},
+					{ID: "api/changelog", Name: "Changelog"},
+					{ID: "index", Name: "Home", IsSelf: true},
+				}},
+			},
+		},
+		{
+			name: "explicit-titles",
+			content: `.. toctree::
+
+   Getting started <intro>
+   Home <self>
+   Python <https://www.python.org>
+   https://example.com/x
+`,
+			want: []types.TocTree{
+				{Items: []types.TOCItem{
+					{ID: "intro", Name: "Getting started"},
+					{ID: "index", Name: "Home", IsSelf: true},
+					{ID: "www.python.org", Name: "Python", IsExternalLink: true, URL: "https://www.python.org"},
+					{ID: "example.com-x", Name: "https://example.com/x", IsExternalLink: true, URL: "https://example.com/x"},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTocTrees(tt.content, "index", testSources(t, dir))
+			if err != nil {
+				t.Fatalf("ParseTocTrees() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTocTrees() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+// childWeightOffset orders child documents after the section pages of their
+// parent, which are weighted 10, 20, 30 and so on.
+const childWeightOffset = 1000
+
+// BuildSiteTree follows the toctrees of every document reachable from the
+// root TOC and builds the document hierarchy. A document listed in more than
+// one toctree is placed under the first one encountered.
+func BuildSiteTree(sources *Sources, toc []types.TOCItem) (*types.SiteTree, error) {
+	root := &types.DocNode{
+		DocName:   sources.RootDoc,
+		Title:     "Overview",
+		OutputDir: "overview",
+	}
+	tree := &types.SiteTree{
+		Root:  root,
+		Nodes: map[string]*types.DocNode{root.DocName: root},
+	}
+
+	// Register all top-level documents first so they stay at the top level
+	// even if a nested toctree lists them too.
+	var topLevel []*types.DocNode
+	for _, item := range toc {
+		if item.IsExternalLink || item.IsSelf || tree.Nodes[item.ID] != nil {
+			continue
+		}
+		node := &types.DocNode{
+			DocName:   item.ID,
+			Title:     item.Name,
+			OutputDir: TopLevelOutputDir(item.ID),
+			Weight:    (len(topLevel) + 1) * 10,
+			Parent:    root,
+		}
+		tree.Nodes[node.DocName] = node
+		root.Children = append(root.Children, node)
+		topLevel = append(topLevel, node)
+	}
+
+	// Output directories taken, by the document written to them
+	taken := map[string]string{root.OutputDir: root.DocName}
+	for _, node := range topLevel {
+		if err := claimOutputDir(taken, node); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range topLevel {
+		if err := addChildren(tree, sources, node, taken); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// addChildren adds the documents listed in the toctrees of node, recursively.
+func addChildren(tree *types.SiteTree, sources *Sources, node *types.DocNode, taken map[string]string) error {
+	content, err := sources.Read(node.DocName)
+	if err != nil {
+		return err
+	}
+
+	trees, err := ParseTocTrees(content, node.DocName, sources)
+	if err != nil {
+		return fmt.Errorf("failed to parse table of contents of %s: %w", node.DocName, err)
+	}
+
+	for _, toc := range trees {
+		for _, item := range toc.Items {
+			if item.IsExternalLink || item.IsSelf || tree.Nodes[item.ID] != nil {
+				continue
+			}
+			child := &types.DocNode{
+				DocName:   item.ID,
+				Title:     item.Name,
+				OutputDir: path.Join(node.OutputDir, childOutputDir(node.DocName, item.ID)),
+				Weight:    childWeightOffset + (len(node.Children)+1)*10,
+				Parent:    node,
+			}
+			if err := claimOutputDir(taken, child); err != nil {
+				return err
+			}
+			tree.Nodes[child.DocName] = child
+			node.Children = append(node.Children, child)
+			if err := addChildren(tree, sources, child, taken); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// childOutputDir returns the output directory of the document docName listed
+// in a toctree of parent, relative to that of the parent: its path relative
+// to the directory of the parent, or its base name if it lies outside it.
+func childOutputDir(parent, docName string) string {
+	rel := TopLevelOutputDir(docName)
+	if dir := path.Dir(parent); dir != "." {
+		rel = strings.TrimPrefix(rel, dir+"/")
+		if rel == TopLevelOutputDir(docName) {
+			rel = path.Base(rel)
+		}
+	}
+	return rel
+}
+
+// claimOutputDir records the output directory of node as taken, or returns
+// an error if another document is written there already.
+func claimOutputDir(taken map[string]string, node *types.DocNode) error {
+	if other, ok := taken[node.OutputDir]; ok {
+		return fmt.Errorf("documents %s and %s would both be written to %s", other, node.DocName, node.OutputDir)
+	}
+	taken[node.OutputDir] = node.DocName
+	return nil
+}
+
+// TopLevelOutputDir returns the output directory of a document listed in the
+// root toctree. A document named index stands for its directory.
+func TopLevelOutputDir(docName string) string {
+	if dir := strings.TrimSuffix(docName, "/index"); dir != docName && dir != "" {
+		return dir
+	}
+	return docName
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestBuildSiteTree(t *testing.T) {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"intro":         "Introduction",
+		"guide/install": "Installing",
+		"guide/usage":   "Usage",
+		"guide/ref/api": "API",
+		"other/faq":     "FAQ",
+	})
+	guide := "Guide\n=====\n\n.. toctree::\n\n   install\n   usage\n   ref/api\n   /other/faq\n   /intro\n"
+	if err := os.WriteFile(filepath.Join(dir, "guide", "index.rst"), []byte(guide), config.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+
+	toc := []types.TOCItem{
+		{ID: "intro", Name: "Introduction"},
+		{ID: "guide/index", Name: "Guide"},
+	}
+	tree, err := BuildSiteTree(testSources(t, dir), toc)
+	if err != nil {
+		t.Fatalf("BuildSiteTree() error = %v", err)
+	}
+
+	tests := []struct {
+		docName   string
+		outputDir string
+		weight    int
+		parent    string
+	}{
+		{docName: "intro", outputDir: "intro", weight: 10, parent: "index"},
+		{docName: "guide/index", outputDir: "guide", weight: 20, parent: "index"},
+		{docName: "guide/install", outputDir: "guide/install", weight: 1010, parent: "guide/index"},
+		{docName: "guide/usage", outputDir: "guide/usage", weight: 1020, parent: "guide/index"},
+		{docName: "guide/ref/api", outputDir: "guide/ref/api", weight: 1030, parent: "guide/index"},
+		{docName: "other/faq", outputDir: "guide/faq", weight: 1040, parent: "guide/index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.docName, func(t *testing.T) {
+			node := tree.Nodes[tt.docName]
+			if node == nil {
+				t.Fatalf("document %s missing from tree", tt.docName)
+			}
+			if node.OutputDir != tt.outputDir || node.Weight != tt.weight || node.Parent.DocName != tt.parent {
+				t.Errorf("node = {%s %d %s}, want {%s %d %s}", node.OutputDir, node.Weight, node.Parent.DocName, tt.outputDir, tt.weight, tt.parent)
+			}
+		})
+	}
+	if n := len(tree.Nodes["guide/index"].Children); n != 4 {
+		t.Errorf("guide/index has %d children, want 4 since intro is already top-level", n)
+	}
+}
+
+func TestBuildSiteTreeOutputDirCollision(t *testing.T) {
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"a/install": "Installing A",
+		"b/install": "Installing B",
+	})
+	guide := "Guide\n=====\n\n.. toctree::\n\n   a/install\n   b/install\n"
+	if err := os.WriteFile(filepath.Join(dir, "guide.rst"), []byte(guide), config.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BuildSiteTree(testSources(t, dir), []types.TOCItem{{ID: "guide", Name: "Guide"}}); err != nil {
+		t.Fatalf("BuildSiteTree() error = %v, want a/install and b/install kept apart", err)
+	}
+
+	other := "Other\n=====\n\n.. toctree::\n\n   /a/install\n   /b/install\n"
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), config.DirPermission); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "other.rst"), []byte(other), config.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	_, err := BuildSiteTree(testSources(t, dir), []types.TOCItem{{ID: "docs/other", Name: "Other"}})
+	if err == nil || !strings.Contains(err.Error(), "docs/other/install") {
+		t.Errorf("BuildSiteTree() error = %v, want a collision at docs/other/install", err)
+	}
+}
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// Watch runs the conversion, then polls the input directory every interval
+// and runs it again whenever a file is added, removed or modified. The files
+// written by a run are removed before the next one, so that the pages of
+// removed documents do not linger, while files that were in the output
+// directory before the first run are kept. done is called with the result of
+// every run. Watch returns when ctx is done.
+func Watch(ctx context.Context, cfg config.Config, interval time.Duration, done func(error)) error {
+	last, err := snapshot(cfg.InputDir, cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+	kept, err := snapshot(cfg.OutputDir, "")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	written, err := runOnce(cfg, kept, done)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := snapshot(cfg.InputDir, cfg.OutputDir)
+		if err != nil {
+			return err
+		}
+		if changed(last, current) {
+			last = current
+			if err := removeFiles(cfg.OutputDir, written); err != nil {
+				return err
+			}
+			if written, err = runOnce(cfg, kept, done); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runOnce runs the conversion, calls done with its result and returns the
+// files it wrote: those in the output directory that are not in kept as they
+// were.
+func runOnce(cfg config.Config, kept map[string][2]int64, done func(error)) (map[string][2]int64, error) {
+	done(Run(cfg))
+	files, err := snapshot(cfg.OutputDir, "")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for path, stat := range kept {
+		if files[path] == stat {
+			delete(files, path)
+		}
+	}
+	return files, nil
+}
+
+// removeFiles removes files and the directories below dir they leave empty.
+func removeFiles(dir string, files map[string][2]int64) error {
+	root := filepath.Clean(dir) + string(filepath.Separator)
+	dirs := map[string]bool{}
+	for path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for parent := filepath.Dir(path); strings.HasPrefix(parent, root); parent = filepath.Dir(parent) {
+			dirs[parent] = true
+		}
+	}
+	// Deeper directories first, so that their parents can empty
+	var sorted []string
+	for path := range dirs {
+		sorted = append(sorted, path)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, path := range sorted {
+		if empty, err := utils.IsDirEmpty(path); err == nil && empty {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// snapshot returns the modification time and size of every file below dir,
+// skipping the directory skip in case the output is written inside the input.
+func snapshot(dir, skip string) (map[string][2]int64, error) {
+	files := map[string][2]int64{}
+	skip = filepath.Clean(skip)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && filepath.Clean(path) == skip {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files[path] = [2]int64{info.ModTime().UnixNano(), info.Size()}
+		}
+		return nil
+	})
+	return files, err
+}
+
+func changed(last, current map[string][2]int64) bool {
+	if len(last) != len(current) {
+		return true
+	}
+	for path, stat := range current {
+		if last[path] != stat {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+)
+
+func TestWatchRemovesStalePages(t *testing.T) {
+	input, output := t.TempDir(), t.TempDir()
+	writeFiles(t, input, map[string]string{
+		"index.rst":         "Home\n====\n\n.. toctree::\n\n   guide/install\n   usage\n",
+		"guide/install.rst": "Install\n=======\n\nSteps.\n",
+		"usage.rst":         "Usage\n=====\n\nCalls.\n",
+	})
+	writeFiles(t, output, map[string]string{"notes.txt": "Kept.\n"})
+	cfg := config.Config{InputDir: input, OutputDir: output, Converter: "native", Force: true, MaxParallel: 1, Depth: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := 0
+	err := Watch(ctx, cfg, 10*time.Millisecond, func(err error) {
+		if err != nil {
+			t.Errorf("run %d: %v", runs, err)
+		}
+		runs++
+		switch runs {
+		case 1:
+			writeFiles(t, input, map[string]string{"index.rst": "Home\n====\n\n.. toctree::\n\n   usage\n"})
+			if err := os.RemoveAll(filepath.Join(input, "guide")); err != nil {
+				t.Fatal(err)
+			}
+		case 2:
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("Watch() error = %v, want %v", err, context.Canceled)
+	}
+
+	for path, want := range map[string]bool{"notes.txt": true, "usage/_index.md": true, "guide": false} {
+		if _, err := os.Stat(filepath.Join(output, filepath.FromSlash(path))); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", path, err == nil, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/rst"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// Link targets standing in for cross-references until the final page layout
+// is known; see ResolveLinks.
+const (
+	refScheme = "rst2md-ref:"
+	docScheme = "rst2md-doc:"
+)
+
+// targetDirectives are the directives rst2md writes an anchor for when they
+// are given a :name:.
+var targetDirectives = map[string]bool{
+	"code-block": true,
+	"sourcecode": true,
+	"code":       true,
+	"image":      true,
+	"figure":     true,
+	"table":      true,
+	"list-table": true,
+	"csv-table":  true,
+	"math":       true,
+}
+
+var (
+	xrefRoleRegex     = regexp.MustCompile("(?s):(?:std:)?(ref|doc|any|numref|term):`((?:[^`\\\\]|\\\\.)+)`")
+	labelTargetRegex  = regexp.MustCompile(`^(\s*)\.\. _([^:` + "`" + `]+):\s*$`)
+	linkTextEscapeReg = regexp.MustCompile("([`<>\\\\])")
+)
+
+// LabelIndex maps Sphinx cross-reference targets to the documents and
+// sections defining them.
+type LabelIndex struct {
+	Labels map[string]types.Label // Keyed by lower-case label name
+	Titles map[string]string      // Document titles keyed by document name
+}
+
+// BuildLabelIndex collects the labels and document titles of every source
+// document.
+func BuildLabelIndex(sources *Sources) (*LabelIndex, error) {
+	docNames, err := sources.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	index := &LabelIndex{
+		Labels: map[string]types.Label{},
+		Titles: map[string]string{},
+	}
+	for _, docName := range docNames {
+		content, err := sources.Read(docName)
+		if err != nil {
+			return nil, err
+		}
+		index.AddDocument(docName, content)
+	}
+	return index, nil
+}
+
+// AddDocument records the title and labels of a document.
+func (ix *LabelIndex) AddDocument(docName, content string) {
+	var pending []string
+	rst.Walk(rst.Parse(content).Children, func(n rst.Node) bool {
+		switch n := n.(type) {
+		case *rst.Target:
+			if n.URL == "" && !n.Anonymous {
+				pending = append(pending, strings.ToLower(n.Name))
+			}
+			return false
+		case *rst.Section:
+			title := rst.PlainText(n.Title)
+			if _, ok := ix.Titles[docName]; !ok {
+				ix.Titles[docName] = title
+			}
+			for _, name := range pending {
+				ix.Labels[name] = types.Label{DocName: docName, Title: title, Section: true}
+			}
+			pending = nil
+			return true
+		case *rst.Directive:
+			// Named code blocks and figures are targets too, titled by
+			// their caption
+			if name, ok := n.Option("name"); ok && targetDirectives[strings.ToLower(n.Name)] {
+				ix.Labels[strings.ToLower(name)] = types.Label{DocName: docName, Title: directiveCaption(n, name)}
+			}
+			if strings.ToLower(n.Name) == "glossary" {
+				for _, entry := range parseGlossary(n.Content) {
+					for _, term := range entry.Terms {
+						ix.Labels[termLabel(term)] = types.Label{DocName: docName, Title: term, Term: true}
+					}
+				}
+			}
+		}
+		for _, name := range pending {
+			ix.Labels[name] = types.Label{DocName: docName, Title: name}
+		}
+		pending = nil
+		return true
+	})
+	for _, name := range pending {
+		ix.Labels[name] = types.Label{DocName: docName, Title: name}
+	}
+
+	// Objects of domain directives are linked to by their full names
+	for _, object := range domainObjects(content) {
+		ix.Labels[object.Label] = types.Label{DocName: docName, Title: object.Name}
+	}
+}
+
+// directiveCaption returns the caption of a named directive: its :caption:
+// option, the first paragraph of a figure, or else its name.
+func directiveCaption(d *rst.Directive, name string) string {
+	if caption, ok := d.Option("caption"); ok {
+		return caption
+	}
+	if strings.HasSuffix(strings.ToLower(d.Name), "table") && strings.TrimSpace(d.Argument) != "" {
+		return strings.TrimSpace(d.Argument)
+	}
+	if len(d.Children) > 0 {
+		if p, ok := d.Children[0].(*rst.Paragraph); ok && strings.ToLower(d.Name) == "figure" {
+			return rst.PlainText(p.Content)
+		}
+	}
+	return name
+}
+
+// RewriteReferences replaces :ref:, :doc:, :any:, :numref: and :term: roles,
+// and domain roles such as :py:func:, in the source of docName with anonymous
+// hyperlinks to placeholder targets, and labels that do not precede a section
+// with explicit HTML anchors, so that both survive conversion and can be
+// resolved by ResolveLinks. Domain roles naming objects no document describes
+// become inline literals.
+func (ix *LabelIndex) RewriteReferences(docName, src string) string {
+	src = rst.MapText(src, func(text string) string {
+		return xrefRoleRegex.ReplaceAllStringFunc(text, func(match string) string {
+			m := xrefRoleRegex.FindStringSubmatch(match)
+			title, target, explicit := rst.SplitEmbedded(m[2])
+			text, link := ix.reference(docName, m[1], target)
+			if explicit {
+				text = title
+			}
+			return "`" + linkTextEscapeReg.ReplaceAllString(text, `\$1`) + " <" + link + ">`__"
+		})
+	})
+	src = rst.MapText(src, func(text string) string {
+		return domainRoleRegex.ReplaceAllStringFunc(text, func(match string) string {
+			m := domainRoleRegex.FindStringSubmatch(match)
+			domain, role := m[1], m[2]
+			if domain == "" {
+				domain = "py"
+			}
+			if !domainRoles[role] || (domain == "http") != domainObjectTypes["http"][role] {
+				return match
+			}
+			text, link, ok := ix.domainReference(domain, role, m[3])
+			if !ok {
+				// Objects documented elsewhere, such as in the standard
+				// library, are shown as code
+				return "``" + text + "``"
+			}
+			return "`" + linkTextEscapeReg.ReplaceAllString(text, `\$1`) + " <" + link + ">`__"
+		})
+	})
+
+	lines := strings.Split(src, "\n")
+	literal := rst.LiteralLines(lines)
+	for i, line := range lines {
+		m := labelTargetRegex.FindStringSubmatch(line)
+		if m == nil || literal[i] {
+			continue
+		}
+		label, ok := ix.Labels[strings.ToLower(m[2])]
+		if !ok || label.Section || label.DocName != docName {
+			continue
+		}
+		lines[i] = fmt.Sprintf("%s.. raw:: html\n\n%s   <a id=\"%s\"></a>\n", m[1], m[1], utils.HeadingAnchor(m[2]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reference returns the default link text and placeholder target of a
+// cross-reference role.
+func (ix *LabelIndex) reference(docName, role, target string) (string, string) {
+	label := strings.ToLower(strings.TrimPrefix(target, "~"))
+	if role == "term" {
+		// Terms are shown as written
+		return target, refScheme + escapeTarget(termLabel(target))
+	}
+	if role != "doc" {
+		if l, ok := ix.Labels[label]; ok {
+			return l.Title, refScheme + escapeTarget(label)
+		}
+		if role != "any" {
+			return target, refScheme + escapeTarget(label)
+		}
+	}
+
+	doc := resolveDocName(docName, target)
+	if title, ok := ix.Titles[doc]; ok {
+		return title, docScheme + escapeTarget(doc)
+	}
+	return target, docScheme + escapeTarget(doc)
+}
+
+func escapeTarget(target string) string {
+	return strings.ReplaceAll(target, " ", "%20")
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/types"
+)
+
+func TestRewriteReferences(t *testing.T) {
+	ix := &LabelIndex{Labels: map[string]types.Label{}, Titles: map[string]string{}}
+	ix.AddDocument("intro", ".. _intro-label:\n\nIntro\n=====\n\n.. _note:\n\nA note.\n")
+	ix.AddDocument("guide/index", "Guide\n=====\n\n.. _install:\n\nInstalling\n----------\n\n.. glossary::\n\n   SDK\n      Kit.\n")
+
+	tests := []struct {
+		name    string
+		docName string
+		src     string
+		want    string
+	}{
+		{
+			name:    "section label",
+			docName: "guide/index",
+			src:     "See :ref:`intro-label`.",
+			want:    "See `Intro <rst2md-ref:intro-label>`__.",
+		},
+		{
+			name:    "explicit title",
+			docName: "intro",
+			src:     "See :ref:`how <install>`.",
+			want:    "See `how <rst2md-ref:install>`__.",
+		},
+		{
+			name:    "glossary term",
+			docName: "intro",
+			src:     "An :term:`sdk` and :term:`kits <Kit>`.",
+			want:    "An `sdk <rst2md-ref:term-sdk>`__ and `kits <rst2md-ref:term-kit>`__.",
+		},
+		{
+			name:    "relative doc",
+			docName: "guide/index",
+			src:     "See :doc:`../intro`.",
+			want:    "See `Intro <rst2md-doc:intro>`__.",
+		},
+		{
+			name:    "unknown label keeps target as text",
+			docName: "intro",
+			src:     "See :ref:`missing`.",
+			want:    "See `missing <rst2md-ref:missing>`__.",
+		},
+		{
+			name:    "inline literal untouched",
+			docName: "intro",
+			src:     "Write ``:ref:`intro-label```.",
+			want:    "Write ``:ref:`intro-label```.",
+		},
+		{
+			name:    "paragraph label becomes anchor",
+			docName: "intro",
+			src:     ".. _note:\n\nA note.",
+			want:    ".. raw:: html\n\n   <a id=\"note\"></a>\n\n\nA note.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ix.RewriteReferences(tt.docName, tt.src)
+			if got != tt.want {
+				t.Errorf("RewriteReferences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	tests := map[string]string{
+		"intro/_index.md":           "/intro/",
+		"guide/install/steps.md":    "/guide/install/steps/",
+		"overview/_index.md":        "/overview/",
+		"guide/install/_index.md":   "/guide/install/",
+		"guide/install/advanced.md": "/guide/install/advanced/",
+	}
+	for relPath, want := range tests {
+		if got := PageURL(relPath); got != want {
+			t.Errorf("PageURL(%q) = %q, want %q", relPath, got, want)
+		}
+	}
+}
+
+func TestResolveLinks(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	writeDocs(t, inputDir, map[string]string{
+		"intro":         "Intro",
+		"guide/install": "Installing",
+	})
+	project, err := NewProject(config.Config{}, testSources(t, inputDir), &types.SiteTree{Nodes: map[string]*types.DocNode{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := map[string]string{
+		"intro/_index.md":         "---\ntitle: Intro\n---\n\n[a](guide/install.rst) [b](guide/install.html#usage) [c](missing.rst) [d](rst2md-ref:nope) ![e](pic.md)\n",
+		"guide/install/_index.md": "---\ntitle: Installing\n---\n\n[back](../intro.html)\n\n```md\n[code](../intro.html)\n```\n",
+		"guide/install/usage.md":  "---\ntitle: Usage\n---\n\n## Flags\n",
+	}
+	writeFiles(t, outputDir, pages)
+
+	unresolved, err := ResolveLinks(outputDir, project)
+	if err != nil {
+		t.Fatalf("ResolveLinks() error = %v", err)
+	}
+	want := []types.UnresolvedLink{
+		{Page: "intro/_index.md", Target: ":ref:`nope`"},
+		{Page: "intro/_index.md", Target: "missing.rst"},
+	}
+	if !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("unresolved = %v, want %v", unresolved, want)
+	}
+
+	tests := map[string]string{
+		"intro/_index.md":         "[a](/guide/install/) [b](/guide/install/usage/) [c](missing.rst) d ![e](pic.md)",
+		"guide/install/_index.md": "[back](/intro/)\n\n```md\n[code](../intro.html)\n```",
+	}
+	for relPath, want := range tests {
+		content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(relPath)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s = %q, want it to contain %q", relPath, content, want)
+		}
+	}
+}
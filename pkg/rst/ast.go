@@ -0,0 +1,239 @@
+// Package rst implements a reStructuredText parser producing an abstract syntax
+// tree and a renderer turning that tree into GitHub-flavoured Markdown.
+package rst
+
+// Node is a block-level element of a reStructuredText document.
+type Node interface {
+	blockNode()
+}
+
+// Inline is an inline element within a paragraph, title or other text.
+type Inline interface {
+	inlineNode()
+}
+
+// Document is the root of a parsed reStructuredText document.
+type Document struct {
+	Children []Node
+}
+
+// Section is a titled section; Level starts at 1 for the outermost section style.
+type Section struct {
+	Level    int
+	Title    []Inline
+	Children []Node
+}
+
+// Paragraph is a block of inline text.
+type Paragraph struct {
+	Content []Inline
+}
+
+// BulletList is an unordered list.
+type BulletList struct {
+	Items []*ListItem
+}
+
+// EnumeratedList is an ordered list starting at Start.
+type EnumeratedList struct {
+	Start int
+	Items []*ListItem
+}
+
+// ListItem is a single entry of a bullet or enumerated list.
+type ListItem struct {
+	Children []Node
+}
+
+// DefinitionList is a list of terms and their definitions.
+type DefinitionList struct {
+	Items []*DefinitionItem
+}
+
+// DefinitionItem is a term, its optional classifiers and its definition.
+type DefinitionItem struct {
+	Term        []Inline
+	Classifiers [][]Inline
+	Definition  []Node
+}
+
+// FieldList is a list of `:name: body` fields.
+type FieldList struct {
+	Fields []*Field
+}
+
+// Field is a single entry of a field list.
+type Field struct {
+	Name string
+	Body []Node
+}
+
+// LiteralBlock is preformatted text, optionally tagged with a language.
+type LiteralBlock struct {
+	Language string
+	Text     string
+}
+
+// LineBlock is a sequence of lines whose line breaks are significant.
+type LineBlock struct {
+	Lines [][]Inline
+}
+
+// BlockQuote is an indented block of body elements.
+type BlockQuote struct {
+	Children    []Node
+	Attribution []Inline
+}
+
+// Table is a grid or simple table; HeaderRows counts the leading header rows.
+type Table struct {
+	HeaderRows int
+	Rows       []*TableRow
+}
+
+// TableRow is a row of table cells.
+type TableRow struct {
+	Cells []*TableCell
+}
+
+// TableCell is a table cell, spanning ColSpan columns and RowSpan rows.
+type TableCell struct {
+	ColSpan  int
+	RowSpan  int
+	Children []Node
+}
+
+// Option is a directive option, kept in source order.
+type Option struct {
+	Name  string
+	Value string
+}
+
+// Directive is an explicit markup directive such as `.. note::`.
+// Content holds the raw, dedented body lines; Children holds the body parsed
+// as body elements for directives whose content is reStructuredText.
+type Directive struct {
+	Name     string
+	Argument string
+	Options  []Option
+	Content  []string
+	Children []Node
+}
+
+// Option returns the value of the named option and whether it was set.
+func (d *Directive) Option(name string) (string, bool) {
+	for _, o := range d.Options {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// Comment is an explicit markup block that is not a directive or target.
+type Comment struct {
+	Text string
+}
+
+// Target is a hyperlink target `.. _name: url`; URL is empty for internal targets.
+type Target struct {
+	Name      string
+	URL       string
+	Anonymous bool
+}
+
+// Footnote is a footnote or citation definition.
+type Footnote struct {
+	Label    string
+	Citation bool
+	Children []Node
+}
+
+// SubstitutionDefinition is a `.. |name| directive::` definition.
+type SubstitutionDefinition struct {
+	Name      string
+	Directive *Directive
+}
+
+// Transition is a horizontal rule.
+type Transition struct{}
+
+func (*Section) blockNode()                {}
+func (*Paragraph) blockNode()              {}
+func (*BulletList) blockNode()             {}
+func (*EnumeratedList) blockNode()         {}
+func (*DefinitionList) blockNode()         {}
+func (*FieldList) blockNode()              {}
+func (*LiteralBlock) blockNode()           {}
+func (*LineBlock) blockNode()              {}
+func (*BlockQuote) blockNode()             {}
+func (*Table) blockNode()                  {}
+func (*Directive) blockNode()              {}
+func (*Comment) blockNode()                {}
+func (*Target) blockNode()                 {}
+func (*Footnote) blockNode()               {}
+func (*SubstitutionDefinition) blockNode() {}
+func (*Transition) blockNode()             {}
+
+// Text is plain text.
+type Text struct {
+	Text string
+}
+
+// Emphasis is `*text*`.
+type Emphasis struct {
+	Children []Inline
+}
+
+// Strong is `**text**`.
+type Strong struct {
+	Children []Inline
+}
+
+// Literal is an inline literal, written with double backquotes.
+type Literal struct {
+	Text string
+}
+
+// Reference is a hyperlink reference. URL is set for embedded URIs; otherwise
+// Name refers to a hyperlink target defined elsewhere in the document.
+type Reference struct {
+	Text      string
+	URL       string
+	Name      string
+	Anonymous bool
+}
+
+// Role is interpreted text with an explicit or default role, such as a
+// :ref: cross-reference.
+type Role struct {
+	Name string
+	Text string
+}
+
+// FootnoteReference is `[label]_`.
+type FootnoteReference struct {
+	Label string
+}
+
+// SubstitutionReference is `|name|`, optionally followed by `_` to form a link.
+type SubstitutionReference struct {
+	Name      string
+	Reference bool
+}
+
+// InlineTarget is an inline internal target, written as an underscore
+// followed by backquoted text.
+type InlineTarget struct {
+	Text string
+}
+
+func (*Text) inlineNode()                  {}
+func (*Emphasis) inlineNode()              {}
+func (*Strong) inlineNode()                {}
+func (*Literal) inlineNode()               {}
+func (*Reference) inlineNode()             {}
+func (*Role) inlineNode()                  {}
+func (*FootnoteReference) inlineNode()     {}
+func (*SubstitutionReference) inlineNode() {}
+func (*InlineTarget) inlineNode()          {}
@@ -0,0 +1,300 @@
+package rst
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	roleRegex          = regexp.MustCompile("^:([A-Za-z0-9][\\w.+-]*(?::[A-Za-z0-9][\\w.+-]*)*):`")
+	suffixRoleRegex    = regexp.MustCompile(`^:([A-Za-z0-9][\w.+-]*(?::[A-Za-z0-9][\w.+-]*)*):`)
+	footnoteRefRegex   = regexp.MustCompile(`^\[(\d+|#[\w.-]*|\*|[A-Za-z][\w.-]*)\]_`)
+	referenceNameRegex = regexp.MustCompile(`[A-Za-z0-9]+(?:[-._+:][A-Za-z0-9]+)*$`)
+	standaloneURLRegex = regexp.MustCompile(`^(?:https?|ftp)://[^\s<>]+|^mailto:[^\s<>]+`)
+	embeddedRegex      = regexp.MustCompile(`(?s)^(.*?)\s*<([^<>]+)>$`)
+)
+
+// SplitEmbedded splits interpreted text of the form `Title <target>` into its
+// title and target. Without an embedded target, both are the text itself and
+// explicit reports false.
+func SplitEmbedded(text string) (title, target string, explicit bool) {
+	if m := embeddedRegex.FindStringSubmatch(text); m != nil {
+		target = strings.TrimSpace(m[2])
+		if title = m[1]; title == "" {
+			title = target
+		}
+		return title, target, true
+	}
+	return text, text, false
+}
+
+func roleText(text string) string {
+	title, _, _ := SplitEmbedded(text)
+	return strings.TrimPrefix(strings.TrimPrefix(title, "~"), "!")
+}
+
+// isStartBoundary reports whether inline markup may start at s[i].
+func isStartBoundary(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsSpace(r) || strings.ContainsRune(`'"([{<-/:`, r) || r > unicode.MaxASCII && unicode.IsPunct(r)
+}
+
+// isEndBoundary reports whether inline markup may end just before s[j].
+func isEndBoundary(s string, j int) bool {
+	if j >= len(s) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(s[j:])
+	return unicode.IsSpace(r) || strings.ContainsRune(`'")]}>-/:.,;!?\`, r) || r > unicode.MaxASCII && unicode.IsPunct(r)
+}
+
+// followedByText reports whether the start-string ending at s[j] is followed
+// by non-whitespace.
+func followedByText(s string, j int) bool {
+	if j >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[j:])
+	return !unicode.IsSpace(r)
+}
+
+// findEnd returns the index of the end-string delim in s at or after from,
+// or -1 if there is none.
+func findEnd(s string, from int, delim string) int {
+	for j := from; j < len(s); {
+		k := strings.Index(s[j:], delim)
+		if k < 0 {
+			return -1
+		}
+		k += j
+		before, _ := utf8.DecodeLastRuneInString(s[:k])
+		if k > from && !unicode.IsSpace(before) && before != '\\' && isEndBoundary(s, k+len(delim)) {
+			return k
+		}
+		j = k + 1
+	}
+	return -1
+}
+
+// findInterpretedEnd returns the index of the closing backquote of
+// interpreted text, which may be followed by `_`, `__` or a role suffix.
+func findInterpretedEnd(s string, from int) int {
+	for j := from; j < len(s); {
+		k := strings.IndexByte(s[j:], '`')
+		if k < 0 {
+			return -1
+		}
+		k += j
+		before, _ := utf8.DecodeLastRuneInString(s[:k])
+		if k > from && !unicode.IsSpace(before) && before != '\\' {
+			after := k + 1
+			switch {
+			case strings.HasPrefix(s[after:], "__"):
+				after += 2
+			case strings.HasPrefix(s[after:], "_"):
+				after++
+			default:
+				if m := suffixRoleRegex.FindString(s[after:]); m != "" {
+					after += len(m)
+				}
+			}
+			if isEndBoundary(s, after) {
+				return k
+			}
+		}
+		j = k + 1
+	}
+	return -1
+}
+
+// unescape removes backslash escapes; an escaped whitespace disappears entirely.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == ' ' || s[i] == '\n' {
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func parseInline(s string) []Inline {
+	var nodes []Inline
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, &Text{Text: unescape(text.String())})
+			text.Reset()
+		}
+	}
+	emit := func(n Inline) {
+		flush()
+		nodes = append(nodes, n)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c == '\\' && i+1 < len(s) {
+			text.WriteString(s[i : i+2])
+			i += 2
+			continue
+		}
+
+		start := isStartBoundary(s, i)
+
+		switch {
+		case start && strings.HasPrefix(s[i:], "``") && followedByText(s, i+2):
+			if end := findEnd(s, i+2, "``"); end >= 0 {
+				emit(&Literal{Text: s[i+2 : end]})
+				i = end + 2
+				continue
+			}
+
+		case start && strings.HasPrefix(s[i:], "**") && followedByText(s, i+2):
+			if end := findEnd(s, i+2, "**"); end >= 0 {
+				emit(&Strong{Children: []Inline{&Text{Text: unescape(s[i+2 : end])}}})
+				i = end + 2
+				continue
+			}
+
+		case start && c == '*' && followedByText(s, i+1) && !strings.HasPrefix(s[i:], "**"):
+			if end := findEnd(s, i+1, "*"); end >= 0 {
+				emit(&Emphasis{Children: []Inline{&Text{Text: unescape(s[i+1 : end])}}})
+				i = end + 1
+				continue
+			}
+
+		case start && c == ':':
+			if m := roleRegex.FindStringSubmatch(s[i:]); m != nil {
+				open := i + len(m[0])
+				if end := findInterpretedEnd(s, open); end >= 0 {
+					emit(&Role{Name: m[1], Text: s[open:end]})
+					i = end + 1
+					continue
+				}
+			}
+
+		case start && c == '`' && followedByText(s, i+1):
+			if end := findInterpretedEnd(s, i+1); end >= 0 {
+				body := s[i+1 : end]
+				after := end + 1
+				switch {
+				case strings.HasPrefix(s[after:], "__"):
+					emit(newReference(body, true))
+					i = after + 2
+				case strings.HasPrefix(s[after:], "_"):
+					emit(newReference(body, false))
+					i = after + 1
+				default:
+					if m := suffixRoleRegex.FindStringSubmatch(s[after:]); m != nil {
+						emit(&Role{Name: m[1], Text: body})
+						i = after + len(m[0])
+					} else {
+						emit(&Role{Name: "title-reference", Text: body})
+						i = after
+					}
+				}
+				continue
+			}
+
+		case start && strings.HasPrefix(s[i:], "_`"):
+			if end := findEnd(s, i+2, "`"); end >= 0 {
+				emit(&InlineTarget{Text: s[i+2 : end]})
+				i = end + 1
+				continue
+			}
+
+		case start && c == '|' && followedByText(s, i+1):
+			if end := strings.IndexByte(s[i+1:], '|'); end > 0 {
+				end += i + 1
+				after := end + 1
+				ref := strings.HasPrefix(s[after:], "_")
+				if ref {
+					after++
+					if strings.HasPrefix(s[after:], "_") {
+						after++
+					}
+				}
+				if !unicode.IsSpace(rune(s[end-1])) && isEndBoundary(s, after) {
+					emit(&SubstitutionReference{Name: s[i+1 : end], Reference: ref})
+					i = after
+					continue
+				}
+			}
+
+		case start && c == '[':
+			if m := footnoteRefRegex.FindStringSubmatch(s[i:]); m != nil && isEndBoundary(s, i+len(m[0])) {
+				emit(&FootnoteReference{Label: m[1]})
+				i += len(m[0])
+				continue
+			}
+
+		case c == '_' && i > 0:
+			anonymous := strings.HasPrefix(s[i:], "__")
+			after := i + 1
+			if anonymous {
+				after++
+			}
+			if isEndBoundary(s, after) {
+				pending := text.String()
+				if name := referenceNameRegex.FindString(pending); name != "" && isStartBoundary(pending, len(pending)-len(name)) {
+					text.Reset()
+					text.WriteString(pending[:len(pending)-len(name)])
+					emit(&Reference{Text: name, Name: name, Anonymous: anonymous})
+					i = after
+					continue
+				}
+			}
+
+		case start && (c == 'h' || c == 'f' || c == 'm'):
+			if m := standaloneURLRegex.FindString(s[i:]); m != "" {
+				m = strings.TrimRight(m, `.,;:!?)'"`)
+				emit(&Reference{Text: m, URL: m})
+				i += len(m)
+				continue
+			}
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return nodes
+}
+
+// newReference builds a reference from the body of `...`_ interpreted text.
+func newReference(body string, anonymous bool) *Reference {
+	title, target, explicit := SplitEmbedded(body)
+	ref := &Reference{Text: unescape(title), Anonymous: anonymous}
+	switch {
+	case !explicit:
+		ref.Name = normalizeName(body)
+	case strings.HasSuffix(target, "_") && !strings.HasSuffix(target, `\_`):
+		ref.Name = strings.Trim(target[:len(target)-1], "`")
+	default:
+		ref.URL = strings.Join(strings.Fields(target), "")
+	}
+	if title == target && explicit {
+		ref.Text = ref.URL
+	}
+	return ref
+}
+
+// normalizeName collapses whitespace in a reference or target name.
+func normalizeName(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
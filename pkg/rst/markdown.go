@@ -0,0 +1,900 @@
+package rst
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spandigital/presidium-rst-to-markdown/pkg/utils"
+)
+
+// admonitionTitles are the default titles of the Sphinx and docutils admonitions.
+var admonitionTitles = map[string]string{
+	"attention": "Attention",
+	"caution":   "Caution",
+	"danger":    "Danger",
+	"error":     "Error",
+	"hint":      "Hint",
+	"important": "Important",
+	"note":      "Note",
+	"tip":       "Tip",
+	"warning":   "Warning",
+	"seealso":   "See also",
+	"todo":      "Todo",
+}
+
+// ignoredDirectives produce no output.
+var ignoredDirectives = map[string]bool{
+	"toctree":        true,
+	"contents":       true,
+	"sectnum":        true,
+	"index":          true,
+	"meta":           true,
+	"include":        true,
+	"literalinclude": true,
+	"default-role":   true,
+	"role":           true,
+	"title":          true,
+	"header":         true,
+	"footer":         true,
+	"target-notes":   true,
+	"tabularcolumns": true,
+	"autosummary":    true,
+}
+
+// Styles of the tables that cannot be written as pipe tables.
+const (
+	TableHTML      = "html"      // An HTML table
+	TableShortcode = "shortcode" // An HTML table inside a table shortcode
+)
+
+var (
+	lineStartEscapeRegex = regexp.MustCompile(`^(\s*)([#>+=-]|\d+[.)])(\s|$)`)
+	backtickRunRegex     = regexp.MustCompile("`+")
+)
+
+// Render writes doc to w as GitHub-flavoured Markdown.
+func Render(w io.Writer, doc *Document) error {
+	r := newRenderer(doc)
+	out := r.blocks(doc.Children)
+	if notes := r.footnoteDefinitions(); notes != "" {
+		out = joinBlocks(out, notes)
+	}
+	if out != "" {
+		out += "\n"
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+type renderer struct {
+	targets       map[string]string // target name to URL or #anchor
+	anonymous     []string          // anonymous target URLs in document order
+	anonymousNext int
+	substitutions map[string]*Directive
+	footnotes     []*Footnote
+	noteLabels    map[*Footnote]string
+	autoLabels    []string // labels assigned to `[#]_` footnotes in order
+	autoNext      int
+	symbolLabels  []string // labels assigned to `[*]_` footnotes in order
+	symbolNext    int
+	language      string // default language of literal blocks
+	tableStyle    string // style of tables that are not pipe tables
+}
+
+func newRenderer(doc *Document) *renderer {
+	r := &renderer{
+		targets:       map[string]string{},
+		substitutions: map[string]*Directive{},
+		noteLabels:    map[*Footnote]string{},
+		tableStyle:    TableHTML,
+	}
+	r.collect(doc.Children)
+	return r
+}
+
+// RenderTable writes t to w as a pipe table if it has no spans, at most one
+// header row and no more than a paragraph in every cell, and in style
+// otherwise. References in the cells resolve to the targets and footnotes of
+// doc, the document holding the table, which may be nil.
+func RenderTable(w io.Writer, doc *Document, t *Table, style string) error {
+	if doc == nil {
+		doc = &Document{}
+	}
+	r := newRenderer(doc)
+	r.tableStyle = style
+	_, err := io.WriteString(w, r.table(t))
+	return err
+}
+
+// Targets returns the URLs the named hyperlink references of doc resolve to,
+// keyed by lower-case name: the URLs of external targets, and #anchors for
+// internal targets and section titles.
+func Targets(doc *Document) map[string]string {
+	r := newRenderer(doc)
+	targets := make(map[string]string, len(r.targets))
+	for name := range r.targets {
+		targets[name] = r.referenceURL(&Reference{Name: name})
+	}
+	return targets
+}
+
+// collect gathers targets, substitutions and footnotes before rendering.
+func (r *renderer) collect(nodes []Node) {
+	var pending []string
+	used := map[string]bool{}
+	var autoNotes []*Footnote
+
+	Walk(nodes, func(n Node) bool {
+		switch n := n.(type) {
+		case *Target:
+			switch {
+			case n.Anonymous:
+				r.anonymous = append(r.anonymous, n.URL)
+			case n.URL != "":
+				r.targets[strings.ToLower(normalizeName(n.Name))] = n.URL
+			default:
+				pending = append(pending, strings.ToLower(normalizeName(n.Name)))
+			}
+			return false
+		case *Section:
+			title := PlainText(n.Title)
+			anchor := "#" + utils.HeadingAnchor(title)
+			for _, name := range pending {
+				r.targets[name] = anchor
+			}
+			pending = nil
+			if _, ok := r.targets[strings.ToLower(normalizeName(title))]; !ok {
+				r.targets[strings.ToLower(normalizeName(title))] = anchor
+			}
+		case *SubstitutionDefinition:
+			r.substitutions[n.Name] = n.Directive
+			return false
+		case *Footnote:
+			r.footnotes = append(r.footnotes, n)
+			r.noteLabels[n] = strings.TrimPrefix(n.Label, "#")
+			if isDigits(n.Label) {
+				used[n.Label] = true
+			}
+			if n.Label == "#" || n.Label == "*" {
+				autoNotes = append(autoNotes, n)
+			}
+		}
+		for _, name := range pending {
+			r.targets[name] = "#" + utils.HeadingAnchor(name)
+		}
+		pending = nil
+		return true
+	})
+	for _, name := range pending {
+		r.targets[name] = "#" + utils.HeadingAnchor(name)
+	}
+
+	// Number auto-numbered and symbol footnotes, skipping explicit numbers.
+	next := 1
+	for _, n := range autoNotes {
+		for used[strconv.Itoa(next)] {
+			next++
+		}
+		label := strconv.Itoa(next)
+		used[label] = true
+		if n.Label == "#" {
+			r.autoLabels = append(r.autoLabels, label)
+		} else {
+			r.symbolLabels = append(r.symbolLabels, label)
+		}
+		r.noteLabels[n] = label
+	}
+}
+
+func joinBlocks(blocks ...string) string {
+	var parts []string
+	for _, b := range blocks {
+		if b != "" {
+			parts = append(parts, b)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (r *renderer) blocks(nodes []Node) string {
+	var parts []string
+	for _, n := range nodes {
+		if out := r.block(n); out != "" {
+			parts = append(parts, out)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (r *renderer) block(n Node) string {
+	switch n := n.(type) {
+	case *Section:
+		level := n.Level
+		if level > 6 {
+			level = 6
+		}
+		heading := strings.Repeat("#", level) + " " + strings.ReplaceAll(r.inlines(n.Title), "\n", " ")
+		return joinBlocks(heading, r.blocks(n.Children))
+	case *Paragraph:
+		return escapeLineStarts(r.inlines(n.Content))
+	case *BulletList:
+		return r.list(n.Items, func(int) string { return "-" })
+	case *EnumeratedList:
+		return r.list(n.Items, func(i int) string { return strconv.Itoa(n.Start+i) + "." })
+	case *DefinitionList:
+		return r.definitionList(n)
+	case *FieldList:
+		list := &DefinitionList{}
+		for _, f := range n.Fields {
+			list.Items = append(list.Items, &DefinitionItem{Term: []Inline{&Text{Text: f.Name}}, Definition: f.Body})
+		}
+		return r.definitionList(list)
+	case *LiteralBlock:
+		language := n.Language
+		if language == "" {
+			language = r.language
+		}
+		return fence(n.Text, language)
+	case *LineBlock:
+		var lines []string
+		for _, line := range n.Lines {
+			lines = append(lines, r.inlines(line))
+		}
+		return strings.Join(lines, "\\\n")
+	case *BlockQuote:
+		body := r.blocks(n.Children)
+		if len(n.Attribution) > 0 {
+			body = joinBlocks(body, "— "+r.inlines(n.Attribution))
+		}
+		return prefixLines(body, "> ", ">")
+	case *Table:
+		return r.table(n)
+	case *Directive:
+		return r.directive(n)
+	case *Transition:
+		return "------------------------------------------------------------------------"
+	}
+	// Comments, targets, footnotes and substitution definitions produce no
+	// output in place.
+	return ""
+}
+
+func prefixLines(text, prefix, blankPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indentLines(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func escapeLineStarts(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if m := lineStartEscapeRegex.FindStringSubmatchIndex(line); m != nil {
+			marker := m[4]
+			if line[marker] >= '0' && line[marker] <= '9' {
+				// Escape the delimiter of an ordered list marker.
+				marker = m[5] - 1
+			}
+			lines[i] = line[:marker] + `\` + line[marker:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fence wraps text in a fenced code block longer than any backtick run in it.
+func fence(text, language string) string {
+	width := 3
+	for _, run := range backtickRunRegex.FindAllString(text, -1) {
+		if len(run) >= width {
+			width = len(run) + 1
+		}
+	}
+	marker := strings.Repeat("`", width)
+	return marker + language + "\n" + text + "\n" + marker
+}
+
+func (r *renderer) list(items []*ListItem, marker func(int) string) string {
+	tight := true
+	for _, item := range items {
+		if len(item.Children) > 1 {
+			tight = false
+		}
+	}
+	var parts []string
+	for i, item := range items {
+		m := marker(i)
+		body := r.blocks(item.Children)
+		pad := strings.Repeat(" ", len(m)+1)
+		body = indentLines(body, pad)
+		body = m + strings.TrimPrefix(body, pad[:len(pad)-1])
+		parts = append(parts, body)
+	}
+	if tight {
+		return strings.Join(parts, "\n")
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// definitionList renders terms as list items followed by their definition
+// on the next line, since GitHub-flavoured Markdown has no definition lists.
+func (r *renderer) definitionList(n *DefinitionList) string {
+	var parts []string
+	for _, item := range n.Items {
+		term := "**" + r.inlines(item.Term) + "**"
+		for _, classifier := range item.Classifiers {
+			term += " : " + r.inlines(classifier)
+		}
+		body := r.blocks(item.Definition)
+		if body != "" {
+			term += "\\\n" + body
+		}
+		parts = append(parts, "-"+strings.TrimPrefix(indentLines(term, "  "), " "))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (r *renderer) directive(d *Directive) string {
+	name := strings.ToLower(d.Name)
+	if ignoredDirectives[name] {
+		return ""
+	}
+	if title, ok := admonitionTitles[name]; ok {
+		return admonition(name, title, r.blocks(d.Children))
+	}
+
+	switch name {
+	case "admonition":
+		return admonition("admonition", r.inlines(parseInline(d.Argument)), r.blocks(d.Children))
+	case "code", "code-block", "sourcecode":
+		language := d.Argument
+		if language == "" {
+			language = r.language
+		}
+		return fence(strings.Join(d.Content, "\n"), language)
+	case "parsed-literal":
+		return fence(strings.Join(d.Content, "\n"), "")
+	case "highlight":
+		r.language = d.Argument
+		return ""
+	case "math":
+		latex := joinBlocks(d.Argument, strings.Join(d.Content, "\n"))
+		return "$$\n" + latex + "\n$$"
+	case "raw":
+		switch strings.ToLower(d.Argument) {
+		case "html", "markdown", "md", "gfm":
+			return strings.Join(d.Content, "\n")
+		}
+		return ""
+	case "image":
+		return r.image(d)
+	case "figure":
+		return joinBlocks(r.image(d), r.blocks(d.Children))
+	case "rubric":
+		return "**" + r.inlines(parseInline(d.Argument)) + "**"
+	case "topic", "sidebar":
+		return joinBlocks("**"+r.inlines(parseInline(d.Argument))+"**", r.blocks(d.Children))
+	case "epigraph", "highlights", "pull-quote":
+		return r.block(&BlockQuote{Children: d.Children})
+	case "versionadded", "versionchanged", "deprecated":
+		label := map[string]string{
+			"versionadded":   "New in version",
+			"versionchanged": "Changed in version",
+			"deprecated":     "Deprecated since version",
+		}[name]
+		return joinBlocks("*"+label+" "+d.Argument+":*", r.blocks(d.Children))
+	case "container", "compound", "only", "ifconfig", "glossary", "centered", "hlist":
+		return r.blocks(d.Children)
+	case "list-table":
+		if table := ListTable(d); table != nil {
+			return r.table(table)
+		}
+	case "csv-table":
+		if table := CSVTable(d, strings.Join(d.Content, "\n")); table != nil {
+			return r.table(table)
+		}
+	}
+
+	body := r.blocks(d.Children)
+	if body == "" {
+		return ""
+	}
+	return fmt.Sprintf("<div class=\"%s\">\n\n%s\n\n</div>", html.EscapeString(name), body)
+}
+
+// admonition renders an admonition in the same shape Pandoc produces, a div
+// with a nested title div, so later stages can treat both backends alike.
+func admonition(class, title, body string) string {
+	return fmt.Sprintf("<div class=\"%s\">\n\n<div class=\"title\">\n\n%s\n\n</div>\n\n%s\n\n</div>", class, title, body)
+}
+
+func (r *renderer) image(d *Directive) string {
+	alt, _ := d.Option("alt")
+	img := fmt.Sprintf("![%s](%s)", escapeText(alt), strings.Join(strings.Fields(d.Argument), ""))
+	if target, ok := d.Option("target"); ok {
+		img = fmt.Sprintf("[%s](%s)", img, target)
+	}
+	return img
+}
+
+// ListTable builds a table from a list-table directive: a bullet list whose
+// items are bullet lists of cells.
+func ListTable(d *Directive) *Table {
+	if len(d.Children) != 1 {
+		return nil
+	}
+	list, ok := d.Children[0].(*BulletList)
+	if !ok {
+		return nil
+	}
+	table := &Table{}
+	if v, ok := d.Option("header-rows"); ok {
+		table.HeaderRows, _ = strconv.Atoi(v)
+	}
+	for _, item := range list.Items {
+		if len(item.Children) != 1 {
+			return nil
+		}
+		cells, ok := item.Children[0].(*BulletList)
+		if !ok {
+			return nil
+		}
+		row := &TableRow{}
+		for _, cell := range cells.Items {
+			row.Cells = append(row.Cells, &TableCell{ColSpan: 1, RowSpan: 1, Children: cell.Children})
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
+
+// CSVTable builds a table from the options of a csv-table directive and
+// its CSV data.
+func CSVTable(d *Directive, data string) *Table {
+	table := &Table{}
+	parse := func(text string) [][]string {
+		reader := csv.NewReader(strings.NewReader(text))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		reader.TrimLeadingSpace = true
+		if delim, ok := d.Option("delim"); ok && len(delim) == 1 {
+			reader.Comma = rune(delim[0])
+		} else if delim == "tab" {
+			reader.Comma = '\t'
+		}
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil
+		}
+		return records
+	}
+	addRows := func(records [][]string) {
+		for _, record := range records {
+			row := &TableRow{}
+			for _, field := range record {
+				row.Cells = append(row.Cells, &TableCell{
+					ColSpan:  1,
+					RowSpan:  1,
+					Children: (&parser{}).parseBody(splitLines(field), false),
+				})
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	}
+	if header, ok := d.Option("header"); ok {
+		records := parse(header)
+		addRows(records)
+		table.HeaderRows = len(records)
+	}
+	records := parse(data)
+	if records == nil && table.HeaderRows == 0 {
+		return nil
+	}
+	addRows(records)
+	if v, ok := d.Option("header-rows"); ok {
+		n, _ := strconv.Atoi(v)
+		table.HeaderRows += n
+	}
+	return table
+}
+
+// isSimpleTable reports whether t can be written as a pipe table: no spans,
+// at most one header row and cells holding a single paragraph at most.
+func isSimpleTable(t *Table) bool {
+	if t.HeaderRows > 1 || len(t.Rows) == 0 {
+		return false
+	}
+	for _, row := range t.Rows {
+		for _, cell := range row.Cells {
+			if cell.ColSpan > 1 || cell.RowSpan > 1 || len(cell.Children) > 1 {
+				return false
+			}
+			if len(cell.Children) == 1 {
+				if _, ok := cell.Children[0].(*Paragraph); !ok {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (r *renderer) table(t *Table) string {
+	if !isSimpleTable(t) {
+		if r.tableStyle == TableShortcode {
+			return "{{< table >}}\n" + r.htmlTable(t) + "\n{{< /table >}}"
+		}
+		return r.htmlTable(t)
+	}
+	columns := 0
+	for _, row := range t.Rows {
+		if len(row.Cells) > columns {
+			columns = len(row.Cells)
+		}
+	}
+	line := func(cells []string) string {
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	cellText := func(cell *TableCell) string {
+		text := strings.ReplaceAll(r.blocks(cell.Children), "\n", " ")
+		return strings.ReplaceAll(text, "|", `\|`)
+	}
+
+	var lines []string
+	rows := t.Rows
+	if t.HeaderRows == 1 {
+		var header []string
+		for _, cell := range rows[0].Cells {
+			header = append(header, cellText(cell))
+		}
+		lines = append(lines, line(header))
+		rows = rows[1:]
+	} else {
+		lines = append(lines, line(nil))
+	}
+	separator := make([]string, columns)
+	for i := range separator {
+		separator[i] = "---"
+	}
+	lines = append(lines, line(separator))
+	for _, row := range rows {
+		var cells []string
+		for _, cell := range row.Cells {
+			cells = append(cells, cellText(cell))
+		}
+		lines = append(lines, line(cells))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *renderer) htmlTable(t *Table) string {
+	var b strings.Builder
+	b.WriteString("<table>\n")
+	for i, row := range t.Rows {
+		if i == 0 && t.HeaderRows > 0 {
+			b.WriteString("<thead>\n")
+		}
+		if i == t.HeaderRows {
+			b.WriteString("<tbody>\n")
+		}
+		tag := "td"
+		if i < t.HeaderRows {
+			tag = "th"
+		}
+		b.WriteString("<tr>\n")
+		for _, cell := range row.Cells {
+			attrs := ""
+			if cell.ColSpan > 1 {
+				attrs += fmt.Sprintf(" colspan=\"%d\"", cell.ColSpan)
+			}
+			if cell.RowSpan > 1 {
+				attrs += fmt.Sprintf(" rowspan=\"%d\"", cell.RowSpan)
+			}
+			fmt.Fprintf(&b, "<%s%s>%s</%s>\n", tag, attrs, r.htmlBlocks(cell.Children), tag)
+		}
+		b.WriteString("</tr>\n")
+		if i == t.HeaderRows-1 {
+			b.WriteString("</thead>\n")
+		}
+	}
+	if len(t.Rows) > t.HeaderRows {
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
+
+// htmlBlocks renders body elements as HTML for use inside HTML blocks, where
+// Markdown is not interpreted. A single paragraph is rendered without a <p>.
+func (r *renderer) htmlBlocks(nodes []Node) string {
+	if len(nodes) == 1 {
+		if p, ok := nodes[0].(*Paragraph); ok {
+			return r.htmlInlines(p.Content)
+		}
+	}
+	var parts []string
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *Paragraph:
+			parts = append(parts, "<p>"+r.htmlInlines(n.Content)+"</p>")
+		case *BulletList:
+			parts = append(parts, r.htmlList("ul", n.Items))
+		case *EnumeratedList:
+			parts = append(parts, r.htmlList("ol", n.Items))
+		case *LiteralBlock:
+			parts = append(parts, "<pre><code>"+html.EscapeString(n.Text)+"</code></pre>")
+		case *Directive:
+			if strings.HasPrefix(n.Name, "code") || n.Name == "sourcecode" {
+				parts = append(parts, "<pre><code>"+html.EscapeString(strings.Join(n.Content, "\n"))+"</code></pre>")
+			} else if n.Name == "raw" && strings.EqualFold(n.Argument, "html") {
+				parts = append(parts, strings.Join(n.Content, "\n"))
+			} else if len(n.Children) > 0 {
+				parts = append(parts, r.htmlBlocks(n.Children))
+			}
+		default:
+			if children := Children(n); len(children) > 0 {
+				parts = append(parts, r.htmlBlocks(children))
+			}
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func (r *renderer) htmlList(tag string, items []*ListItem) string {
+	var b strings.Builder
+	b.WriteString("<" + tag + ">")
+	for _, item := range items {
+		b.WriteString("<li>" + r.htmlBlocks(item.Children) + "</li>")
+	}
+	b.WriteString("</" + tag + ">")
+	return b.String()
+}
+
+func (r *renderer) htmlInlines(inlines []Inline) string {
+	var b strings.Builder
+	for _, in := range inlines {
+		switch in := in.(type) {
+		case *Text:
+			b.WriteString(html.EscapeString(in.Text))
+		case *Emphasis:
+			b.WriteString("<em>" + r.htmlInlines(in.Children) + "</em>")
+		case *Strong:
+			b.WriteString("<strong>" + r.htmlInlines(in.Children) + "</strong>")
+		case *Literal:
+			b.WriteString("<code>" + html.EscapeString(in.Text) + "</code>")
+		case *Reference:
+			fmt.Fprintf(&b, "<a href=\"%s\">%s</a>", html.EscapeString(r.referenceURL(in)), html.EscapeString(in.Text))
+		default:
+			b.WriteString(html.EscapeString(PlainText([]Inline{in})))
+		}
+	}
+	return b.String()
+}
+
+func (r *renderer) referenceURL(ref *Reference) string {
+	if ref.URL != "" {
+		return ref.URL
+	}
+	if ref.Anonymous {
+		if r.anonymousNext < len(r.anonymous) {
+			url := r.anonymous[r.anonymousNext]
+			r.anonymousNext++
+			return url
+		}
+		return ""
+	}
+	name := strings.ToLower(normalizeName(ref.Name))
+	for i := 0; i < 10; i++ {
+		url, ok := r.targets[name]
+		if !ok {
+			break
+		}
+		// A target may point at another target: `.. _a: b_`.
+		if strings.HasSuffix(url, "_") && !strings.Contains(url, "/") {
+			name = strings.ToLower(strings.TrimSuffix(url, "_"))
+			continue
+		}
+		return url
+	}
+	return "#" + utils.HeadingAnchor(ref.Name)
+}
+
+func (r *renderer) inlines(inlines []Inline) string {
+	var b strings.Builder
+	for _, in := range inlines {
+		b.WriteString(r.inline(in))
+	}
+	return b.String()
+}
+
+func (r *renderer) inline(in Inline) string {
+	switch in := in.(type) {
+	case *Text:
+		return escapeText(in.Text)
+	case *Emphasis:
+		return "*" + r.inlines(in.Children) + "*"
+	case *Strong:
+		return "**" + r.inlines(in.Children) + "**"
+	case *Literal:
+		return codeSpan(in.Text)
+	case *Reference:
+		url := r.referenceURL(in)
+		if in.URL != "" && in.Text == in.URL {
+			return "<" + in.URL + ">"
+		}
+		return "[" + escapeText(in.Text) + "](" + url + ")"
+	case *Role:
+		return r.role(in)
+	case *FootnoteReference:
+		return "[^" + r.footnoteLabel(in.Label) + "]"
+	case *SubstitutionReference:
+		return r.substitution(in)
+	case *InlineTarget:
+		return escapeText(in.Text)
+	}
+	return ""
+}
+
+func codeSpan(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	width := 1
+	for _, run := range backtickRunRegex.FindAllString(text, -1) {
+		if len(run) >= width {
+			width = len(run) + 1
+		}
+	}
+	marker := strings.Repeat("`", width)
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return marker + " " + text + " " + marker
+	}
+	return marker + text + marker
+}
+
+func (r *renderer) role(role *Role) string {
+	switch role.Name {
+	case "code", "literal", "file", "samp", "command", "program", "envvar", "option", "kbd", "mimetype", "makevar", "regexp":
+		return codeSpan(role.Text)
+	case "emphasis", "title-reference", "title", "t", "dfn":
+		return "*" + escapeText(role.Text) + "*"
+	case "strong", "guilabel", "menuselection":
+		return "**" + escapeText(roleText(role.Text)) + "**"
+	case "sub", "subscript":
+		return "<sub>" + escapeText(role.Text) + "</sub>"
+	case "sup", "superscript":
+		return "<sup>" + escapeText(role.Text) + "</sup>"
+	case "math":
+		return "$" + role.Text + "$"
+	case "abbr":
+		return escapeText(roleText(role.Text))
+	}
+	// Other roles, such as Sphinx cross-references, are kept in the same
+	// shape Pandoc produces so later stages can resolve them.
+	return fmt.Sprintf("<span class=\"interpreted-text\" role=\"%s\">%s</span>", html.EscapeString(role.Name), html.EscapeString(role.Text))
+}
+
+func (r *renderer) footnoteLabel(label string) string {
+	switch {
+	case label == "#":
+		if r.autoNext < len(r.autoLabels) {
+			r.autoNext++
+			return r.autoLabels[r.autoNext-1]
+		}
+	case label == "*":
+		if r.symbolNext < len(r.symbolLabels) {
+			r.symbolNext++
+			return r.symbolLabels[r.symbolNext-1]
+		}
+	}
+	return strings.TrimPrefix(label, "#")
+}
+
+func (r *renderer) footnoteDefinitions() string {
+	var parts []string
+	for _, n := range r.footnotes {
+		body := r.blocks(n.Children)
+		parts = append(parts, "[^"+r.noteLabels[n]+"]: "+strings.TrimPrefix(indentLines(body, "    "), "    "))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (r *renderer) substitution(ref *SubstitutionReference) string {
+	d, ok := r.substitutions[ref.Name]
+	if !ok {
+		return escapeText("|" + ref.Name + "|")
+	}
+	var out string
+	switch strings.ToLower(d.Name) {
+	case "replace":
+		out = r.inlines(parseInline(joinBlocks(d.Argument, strings.Join(d.Content, " "))))
+	case "image":
+		alt, ok := d.Option("alt")
+		if !ok {
+			alt = ref.Name
+		}
+		out = fmt.Sprintf("![%s](%s)", escapeText(alt), d.Argument)
+	case "unicode":
+		out = unicodeText(d.Argument)
+	default:
+		return escapeText("|" + ref.Name + "|")
+	}
+	if ref.Reference {
+		url := r.referenceURL(&Reference{Name: ref.Name})
+		return "[" + out + "](" + url + ")"
+	}
+	return out
+}
+
+// unicodeText decodes the arguments of a unicode directive: character codes
+// such as U+2122, 0x2122 or &#x2122; and literal text.
+func unicodeText(arg string) string {
+	if i := strings.Index(arg, " .. "); i >= 0 {
+		arg = arg[:i]
+	}
+	var b strings.Builder
+	for _, field := range strings.Fields(arg) {
+		code := strings.TrimSuffix(field, ";")
+		for _, prefix := range []string{"U+", "u+", "0x", "0X", "x", "\\x", "\\u", "&#x"} {
+			if strings.HasPrefix(code, prefix) {
+				if v, err := strconv.ParseInt(code[len(prefix):], 16, 32); err == nil {
+					b.WriteRune(rune(v))
+					field = ""
+				}
+				break
+			}
+		}
+		if field != "" {
+			if v, err := strconv.Atoi(field); err == nil {
+				b.WriteRune(rune(v))
+			} else {
+				b.WriteString(field)
+			}
+		}
+	}
+	return b.String()
+}
+
+// escapeText escapes characters that Markdown would otherwise interpret.
+func escapeText(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '\\', '`', '*', '[', ']', '<':
+			b.WriteByte('\\')
+		case '_':
+			// Intraword underscores never start emphasis in GFM.
+			if i > 0 && i+1 < len(text) && isWordByte(text[i-1]) && isWordByte(text[i+1]) {
+				break
+			}
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,111 @@
+package rst
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "sections",
+			input: "Title\n=====\n\nText.\n\nSub\n---\n\nMore.\n",
+			want:  "# Title\n\nText.\n\n## Sub\n\nMore.\n",
+		},
+		{
+			name:  "overlined-title",
+			input: "=====\nTitle\n=====\n\nSub\n===\n",
+			want:  "# Title\n\n## Sub\n",
+		},
+		{
+			name:  "inline-markup",
+			input: "Some *em*, **strong**, ``code`` and `a link <https://example.com>`_.\n",
+			want:  "Some *em*, **strong**, `code` and [a link](https://example.com).\n",
+		},
+		{
+			name:  "named-reference",
+			input: "See Python_.\n\n.. _Python: https://www.python.org\n",
+			want:  "See [Python](https://www.python.org).\n",
+		},
+		{
+			name:  "internal-target",
+			input: "Go to install_.\n\n.. _install:\n\nInstalling\n==========\n",
+			want:  "Go to [install](#installing).\n\n# Installing\n",
+		},
+		{
+			name:  "bullet-list",
+			input: "- one\n- two\n",
+			want:  "- one\n- two\n",
+		},
+		{
+			name:  "enumerated-list",
+			input: "3. three\n4. four\n",
+			want:  "3. three\n4. four\n",
+		},
+		{
+			name:  "literal-block",
+			input: "Example::\n\n    x = 1\n",
+			want:  "Example:\n\n```\nx = 1\n```\n",
+		},
+		{
+			name:  "code-block",
+			input: ".. code-block:: go\n\n   fmt.Println()\n",
+			want:  "```go\nfmt.Println()\n```\n",
+		},
+		{
+			name:  "highlight-default",
+			input: ".. highlight:: sh\n\nRun::\n\n   ls\n",
+			want:  "Run:\n\n```sh\nls\n```\n",
+		},
+		{
+			name:  "admonition",
+			input: ".. warning:: Hot.\n",
+			want:  "<div class=\"warning\">\n\n<div class=\"title\">\n\nWarning\n\n</div>\n\nHot.\n\n</div>\n",
+		},
+		{
+			name:  "simple-table",
+			input: "===  ===\nA    B\n===  ===\n1    2\n===  ===\n",
+			want:  "| A | B |\n| --- | --- |\n| 1 | 2 |\n",
+		},
+		{
+			name:  "grid-table-span",
+			input: "+---+---+\n| a | b |\n+---+---+\n| c     |\n+-------+\n",
+			want:  "<table>\n<tbody>\n<tr>\n<td>a</td>\n<td>b</td>\n</tr>\n<tr>\n<td colspan=\"2\">c</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name:  "footnotes",
+			input: "Text [#]_ and [#]_.\n\n.. [#] First.\n.. [#] Second.\n",
+			want:  "Text [^1] and [^2].\n\n[^1]: First.\n\n[^2]: Second.\n",
+		},
+		{
+			name:  "substitution",
+			input: "Use |product|.\n\n.. |product| replace:: rst2md\n",
+			want:  "Use rst2md.\n",
+		},
+		{
+			name:  "role-kept-for-later-stages",
+			input: "See :ref:`install`.\n",
+			want:  "See <span class=\"interpreted-text\" role=\"ref\">install</span>.\n",
+		},
+		{
+			name:  "escaping",
+			input: "A [bracket] and *not emphasis * here.\n",
+			want:  "A \\[bracket\\] and \\*not emphasis \\* here.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := Render(&b, Parse(tt.input)); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,675 @@
+package rst
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	bulletRegex       = regexp.MustCompile(`^([-*+•‣⁃])( +|$)`)
+	enumeratedRegex   = regexp.MustCompile(`^(\(?)(\d+|#|[a-zA-Z]|[ivxlcdm]+|[IVXLCDM]+)([.)])( +|$)`)
+	fieldRegex        = regexp.MustCompile(`^:((?:[^:\\\s]|\\.)(?:[^:\\]|\\.)*):(?: +(.*))?$`)
+	targetRegex       = regexp.MustCompile("^_(`[^`]+`|[^:]+|_):\\s*(.*)$")
+	footnoteRegex     = regexp.MustCompile(`^\[([^\]\s]+)\](?:\s+(.*))?$`)
+	substitutionRegex = regexp.MustCompile(`^\|([^|]+)\|\s+([\w:.+-]+)::\s*(.*)$`)
+	directiveRegex    = regexp.MustCompile(`^([A-Za-z0-9][\w:.+-]*)::(?:\s+(.*))?$`)
+	optionRegex       = regexp.MustCompile(`^:([^:\s][^:]*):(?:\s+(.*))?$`)
+	gridBorderRegex   = regexp.MustCompile(`^\+[-=+]+\+$`)
+	simpleBorderRegex = regexp.MustCompile(`^=+( +=+)*$`)
+)
+
+// contentOnlyDirectives take no arguments, so text on the directive line is
+// the first line of their content.
+var contentOnlyDirectives = map[string]bool{
+	"attention": true,
+	"caution":   true,
+	"danger":    true,
+	"error":     true,
+	"hint":      true,
+	"important": true,
+	"note":      true,
+	"tip":       true,
+	"warning":   true,
+	"seealso":   true,
+	"todo":      true,
+	"glossary":  true,
+	"epigraph":  true,
+}
+
+// rawDirectives have content that is not parsed as reStructuredText.
+var rawDirectives = map[string]bool{
+	"code":            true,
+	"code-block":      true,
+	"sourcecode":      true,
+	"literalinclude":  true,
+	"include":         true,
+	"math":            true,
+	"raw":             true,
+	"csv-table":       true,
+	"toctree":         true,
+	"highlight":       true,
+	"graphviz":        true,
+	"digraph":         true,
+	"graph":           true,
+	"uml":             true,
+	"mermaid":         true,
+	"automodule":      true,
+	"autoclass":       true,
+	"autofunction":    true,
+	"autosummary":     true,
+	"tabularcolumns":  true,
+	"productionlist":  true,
+	"parsed-literal":  true,
+	"replace":         true,
+	"unicode":         true,
+	"image":           true,
+	"meta":            true,
+	"contents":        true,
+	"sectnum":         true,
+	"index":           true,
+	"default-role":    true,
+	"role":            true,
+	"title":           true,
+	"header":          true,
+	"footer":          true,
+	"target-notes":    true,
+	"date":            true,
+	"doctest":         true,
+	"testcode":        true,
+	"testoutput":      true,
+	"program-output":  true,
+	"command-output":  true,
+	"jupyter-execute": true,
+}
+
+type adornment struct {
+	char     rune
+	overline bool
+}
+
+type parser struct {
+	styles []adornment
+}
+
+// Parse parses a reStructuredText document.
+func Parse(src string) *Document {
+	p := &parser{}
+	return &Document{Children: nestSections(p.parseBody(splitLines(src), true))}
+}
+
+// splitLines normalises line endings, expands tabs and strips trailing whitespace.
+func splitLines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "\t") {
+			line = expandTabs(line)
+		}
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return lines
+}
+
+func expandTabs(line string) string {
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// nestSections turns the flat list of section titles produced by the parser
+// into a tree where every section holds the nodes that follow it.
+func nestSections(nodes []Node) []Node {
+	var root []Node
+	var stack []*Section
+	for _, n := range nodes {
+		if s, ok := n.(*Section); ok {
+			for len(stack) > 0 && stack[len(stack)-1].Level >= s.Level {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				root = append(root, s)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, s)
+			}
+			stack = append(stack, s)
+			continue
+		}
+		if len(stack) == 0 {
+			root = append(root, n)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, n)
+		}
+	}
+	return root
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// dedent removes the common leading indentation of the non-blank lines.
+func dedent(lines []string) []string {
+	return dedentBy(lines, minIndent(lines))
+}
+
+func minIndent(lines []string) int {
+	indent := -1
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		if n := indentOf(line); indent == -1 || n < indent {
+			indent = n
+		}
+	}
+	if indent < 0 {
+		return 0
+	}
+	return indent
+}
+
+func dedentBy(lines []string, n int) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= n {
+			out[i] = line[n:]
+		} else {
+			out[i] = strings.TrimLeft(line, " ")
+		}
+	}
+	return out
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// indentedBlock returns the lines from start onwards that are blank or
+// indented by at least minIndent, and the index of the first line after them.
+// The returned lines are not dedented and have trailing blank lines removed.
+func indentedBlock(lines []string, start, minIndent int) ([]string, int) {
+	end := start
+	for end < len(lines) && (isBlank(lines[end]) || indentOf(lines[end]) >= minIndent) {
+		end++
+	}
+	block := lines[start:end]
+	for len(block) > 0 && isBlank(block[len(block)-1]) {
+		block = block[:len(block)-1]
+	}
+	return block, end
+}
+
+// itemBody joins the text following a list or field marker with its
+// indented continuation lines, dedented to the marker's content column.
+func itemBody(first string, rest []string, width int) []string {
+	indent := minIndent(rest)
+	if width > 0 && indent > width {
+		indent = width
+	}
+	body := []string{first}
+	if first == "" {
+		body = nil
+	}
+	return append(body, dedentBy(rest, indent)...)
+}
+
+// IsAdornment reports whether line is a section title adornment: a repeated
+// ASCII punctuation character.
+func IsAdornment(line string) bool {
+	if len(line) < 2 {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(line)
+	if first > unicode.MaxASCII || !unicode.IsPunct(first) && !unicode.IsSymbol(first) {
+		return false
+	}
+	for _, r := range line {
+		if r != first {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) level(a adornment) int {
+	for i, s := range p.styles {
+		if s == a {
+			return i + 1
+		}
+	}
+	p.styles = append(p.styles, a)
+	return len(p.styles)
+}
+
+// parseSectionTitle recognises an underlined or over- and underlined title at lines[i].
+func (p *parser) parseSectionTitle(lines []string, i int) (*Section, int, bool) {
+	line := lines[i]
+	if IsAdornment(line) && i+2 < len(lines) && lines[i+2] == line && !isBlank(lines[i+1]) {
+		title := strings.TrimSpace(lines[i+1])
+		char, _ := utf8.DecodeRuneInString(line)
+		level := p.level(adornment{char: char, overline: true})
+		return &Section{Level: level, Title: parseInline(title)}, i + 3, true
+	}
+	if indentOf(line) > 0 || i+1 >= len(lines) || IsAdornment(line) {
+		return nil, 0, false
+	}
+	underline := lines[i+1]
+	if !IsAdornment(underline) || indentOf(underline) > 0 {
+		return nil, 0, false
+	}
+	titleLen := utf8.RuneCountInString(line)
+	if n := utf8.RuneCountInString(underline); n < titleLen && n < 4 {
+		return nil, 0, false
+	}
+	char, _ := utf8.DecodeRuneInString(underline)
+	level := p.level(adornment{char: char})
+	return &Section{Level: level, Title: parseInline(line)}, i + 2, true
+}
+
+// parseBody parses a sequence of body elements from lines with no common
+// indentation. Section titles are only recognised at the top level.
+func (p *parser) parseBody(lines []string, top bool) []Node {
+	var nodes []Node
+	i := 0
+	for i < len(lines) {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+
+		if indentOf(line) > 0 {
+			block, next := indentedBlock(lines, i, 1)
+			nodes = append(nodes, p.parseBlockQuote(dedent(block)))
+			i = next
+			continue
+		}
+
+		if top {
+			if section, next, ok := p.parseSectionTitle(lines, i); ok {
+				nodes = append(nodes, section)
+				i = next
+				continue
+			}
+		}
+
+		if IsAdornment(line) && len(line) >= 4 && (i+1 == len(lines) || isBlank(lines[i+1])) {
+			nodes = append(nodes, &Transition{})
+			i++
+			continue
+		}
+
+		var node Node
+		var next int
+		switch {
+		case line == ".." || strings.HasPrefix(line, ".. "):
+			node, next = p.parseExplicit(lines, i)
+		case strings.HasPrefix(line, "__ "):
+			block, n := indentedBlock(lines, i+1, 1)
+			url := strings.TrimSpace(line[3:]) + strings.Join(strings.Fields(strings.Join(block, " ")), "")
+			node, next = &Target{Name: "_", URL: url, Anonymous: true}, n
+		case bulletRegex.MatchString(line):
+			node, next = p.parseBulletList(lines, i)
+		case isEnumerated(lines, i):
+			node, next = p.parseEnumeratedList(lines, i)
+		case fieldRegex.MatchString(line):
+			node, next = p.parseFieldList(lines, i)
+		case gridBorderRegex.MatchString(line):
+			node, next = p.parseGridTable(lines, i)
+		case simpleBorderRegex.MatchString(line) && strings.Contains(line, " "):
+			node, next = p.parseSimpleTable(lines, i)
+		case line == "|" || strings.HasPrefix(line, "| "):
+			node, next = p.parseLineBlock(lines, i)
+		case strings.HasPrefix(line, ">>> "):
+			end := i
+			for end < len(lines) && !isBlank(lines[end]) {
+				end++
+			}
+			node, next = &LiteralBlock{Language: "python", Text: strings.Join(lines[i:end], "\n")}, end
+		case i+1 < len(lines) && !isBlank(lines[i+1]) && indentOf(lines[i+1]) > 0:
+			node, next = p.parseDefinitionList(lines, i)
+		default:
+			var extra []Node
+			node, extra, next = p.parseParagraph(lines, i)
+			if node != nil {
+				nodes = append(nodes, node)
+			}
+			nodes = append(nodes, extra...)
+			i = next
+			continue
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+		i = next
+	}
+	return nodes
+}
+
+// parseParagraph parses a paragraph and, if it ends with `::`, the literal block following it.
+func (p *parser) parseParagraph(lines []string, i int) (Node, []Node, int) {
+	end := i
+	for end < len(lines) && !isBlank(lines[end]) {
+		end++
+	}
+	text := strings.Join(lines[i:end], "\n")
+
+	if !strings.HasSuffix(text, "::") {
+		return &Paragraph{Content: parseInline(text)}, nil, end
+	}
+
+	var para Node
+	switch {
+	case strings.TrimSpace(text) == "::":
+	case strings.HasSuffix(text, " ::") || strings.HasSuffix(text, "\n::"):
+		para = &Paragraph{Content: parseInline(strings.TrimSpace(strings.TrimSuffix(text, "::")))}
+	default:
+		para = &Paragraph{Content: parseInline(strings.TrimSuffix(text, ":"))}
+	}
+
+	next := end
+	for next < len(lines) && isBlank(lines[next]) {
+		next++
+	}
+	if next >= len(lines) {
+		return para, nil, next
+	}
+	if indentOf(lines[next]) > 0 {
+		block, after := indentedBlock(lines, next, 1)
+		return para, []Node{&LiteralBlock{Text: strings.Join(dedent(block), "\n")}}, after
+	}
+
+	// Quoted literal block: unindented lines starting with the same punctuation.
+	quote, _ := utf8.DecodeRuneInString(lines[next])
+	if quote <= unicode.MaxASCII && unicode.IsPunct(quote) {
+		after := next
+		for after < len(lines) && strings.HasPrefix(lines[after], string(quote)) {
+			after++
+		}
+		return para, []Node{&LiteralBlock{Text: strings.Join(lines[next:after], "\n")}}, after
+	}
+	return para, nil, next
+}
+
+func (p *parser) parseBlockQuote(lines []string) Node {
+	children := p.parseBody(lines, false)
+	quote := &BlockQuote{Children: children}
+	if len(children) > 0 {
+		if para, ok := children[len(children)-1].(*Paragraph); ok {
+			text := PlainText(para.Content)
+			for _, dash := range []string{"-- ", "— ", "--- "} {
+				if strings.HasPrefix(text, dash) {
+					quote.Children = children[:len(children)-1]
+					quote.Attribution = parseInline(strings.TrimPrefix(text, dash))
+					break
+				}
+			}
+		}
+	}
+	return quote
+}
+
+func (p *parser) parseBulletList(lines []string, i int) (Node, int) {
+	list := &BulletList{}
+	bullet := bulletRegex.FindStringSubmatch(lines[i])[1]
+	for i < len(lines) {
+		m := bulletRegex.FindStringSubmatch(lines[i])
+		if m == nil || m[1] != bullet || indentOf(lines[i]) > 0 {
+			break
+		}
+		width := len(m[0])
+		if m[2] == "" {
+			width = len(m[1]) + 1
+		}
+		rest, next := indentedBlock(lines, i+1, 1)
+		first := strings.TrimPrefix(lines[i], m[0])
+		list.Items = append(list.Items, &ListItem{Children: p.parseBody(itemBody(first, rest, width), false)})
+		i = skipBlank(lines, next)
+	}
+	return list, i
+}
+
+func skipBlank(lines []string, i int) int {
+	for i < len(lines) && isBlank(lines[i]) {
+		i++
+	}
+	return i
+}
+
+// isEnumerated reports whether lines[i] starts an enumerated list. Single
+// letters and roman numerals also start ordinary sentences, so they only count
+// when the next line is blank, indented or another list item.
+func isEnumerated(lines []string, i int) bool {
+	m := enumeratedRegex.FindStringSubmatch(lines[i])
+	if m == nil || (m[1] == "(" && m[3] != ")") {
+		return false
+	}
+	if _, ok := enumeratorValue(m[2]); !ok {
+		return false
+	}
+	if m[2] == "#" || isDigits(m[2]) {
+		return true
+	}
+	return i+1 >= len(lines) || isBlank(lines[i+1]) || indentOf(lines[i+1]) > 0 || enumeratedRegex.MatchString(lines[i+1])
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func enumeratorValue(s string) (int, bool) {
+	switch {
+	case s == "#":
+		return 0, true
+	case isDigits(s):
+		n := 0
+		for _, r := range s {
+			n = n*10 + int(r-'0')
+		}
+		return n, true
+	case len(s) == 1 && unicode.IsLetter(rune(s[0])) && !strings.ContainsRune("ivxlcdmIVXLCDM", rune(s[0])):
+		return int(unicode.ToLower(rune(s[0]))-'a') + 1, true
+	}
+	return romanValue(strings.ToLower(s))
+}
+
+func romanValue(s string) (int, bool) {
+	values := map[rune]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100, 'd': 500, 'm': 1000}
+	total, prev := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		v, ok := values[rune(s[i])]
+		if !ok {
+			return 0, false
+		}
+		if v < prev {
+			total -= v
+		} else {
+			total += v
+			prev = v
+		}
+	}
+	return total, total > 0
+}
+
+func (p *parser) parseEnumeratedList(lines []string, i int) (Node, int) {
+	list := &EnumeratedList{Start: 1}
+	first := enumeratedRegex.FindStringSubmatch(lines[i])
+	if n, _ := enumeratorValue(first[2]); n > 0 {
+		list.Start = n
+	}
+	for i < len(lines) {
+		m := enumeratedRegex.FindStringSubmatch(lines[i])
+		if m == nil || m[1] != first[1] || m[3] != first[3] || indentOf(lines[i]) > 0 {
+			break
+		}
+		width := len(m[0])
+		if m[4] == "" {
+			width = len(m[0]) + 1
+		}
+		rest, next := indentedBlock(lines, i+1, 1)
+		text := strings.TrimPrefix(lines[i], m[0])
+		list.Items = append(list.Items, &ListItem{Children: p.parseBody(itemBody(text, rest, width), false)})
+		i = skipBlank(lines, next)
+	}
+	return list, i
+}
+
+func (p *parser) parseFieldList(lines []string, i int) (Node, int) {
+	list := &FieldList{}
+	for i < len(lines) && indentOf(lines[i]) == 0 {
+		m := fieldRegex.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		rest, next := indentedBlock(lines, i+1, 1)
+		name := strings.ReplaceAll(m[1], `\:`, ":")
+		list.Fields = append(list.Fields, &Field{Name: name, Body: p.parseBody(itemBody(m[2], rest, 0), false)})
+		i = skipBlank(lines, next)
+	}
+	return list, i
+}
+
+func (p *parser) parseDefinitionList(lines []string, i int) (Node, int) {
+	list := &DefinitionList{}
+	for i+1 < len(lines) && indentOf(lines[i]) == 0 && !isBlank(lines[i]) && !isBlank(lines[i+1]) && indentOf(lines[i+1]) > 0 {
+		parts := strings.Split(lines[i], " : ")
+		item := &DefinitionItem{Term: parseInline(parts[0])}
+		for _, classifier := range parts[1:] {
+			item.Classifiers = append(item.Classifiers, parseInline(classifier))
+		}
+		block, next := indentedBlock(lines, i+1, 1)
+		item.Definition = p.parseBody(dedent(block), false)
+		list.Items = append(list.Items, item)
+		i = skipBlank(lines, next)
+	}
+	return list, i
+}
+
+func (p *parser) parseLineBlock(lines []string, i int) (Node, int) {
+	block := &LineBlock{}
+	for i < len(lines) && !isBlank(lines[i]) {
+		line := lines[i]
+		switch {
+		case line == "|":
+			block.Lines = append(block.Lines, nil)
+		case strings.HasPrefix(line, "| "):
+			block.Lines = append(block.Lines, parseInline(strings.TrimSpace(line[2:])))
+		case len(block.Lines) > 0:
+			last := len(block.Lines) - 1
+			block.Lines[last] = append(block.Lines[last], &Text{Text: " "})
+			block.Lines[last] = append(block.Lines[last], parseInline(strings.TrimSpace(line))...)
+		}
+		i++
+	}
+	return block, i
+}
+
+// parseExplicit parses an explicit markup block: a directive, target,
+// footnote, citation, substitution definition or comment.
+func (p *parser) parseExplicit(lines []string, i int) (Node, int) {
+	first := strings.TrimPrefix(strings.TrimPrefix(lines[i], ".."), " ")
+	rest, next := indentedBlock(lines, i+1, 1)
+	body := dedent(rest)
+
+	if m := targetRegex.FindStringSubmatch(first); m != nil {
+		name := strings.Trim(m[1], "`")
+		url := m[2] + strings.Join(strings.Fields(strings.Join(body, " ")), "")
+		if name == "_" {
+			return &Target{Name: name, URL: url, Anonymous: true}, next
+		}
+		return &Target{Name: name, URL: url}, next
+	}
+
+	if m := footnoteRegex.FindStringSubmatch(first); m != nil {
+		label := m[1]
+		citation := !(isDigits(label) || strings.HasPrefix(label, "#") || label == "*")
+		content := itemBody(m[2], rest, 0)
+		return &Footnote{Label: label, Citation: citation, Children: p.parseBody(content, false)}, next
+	}
+
+	if m := substitutionRegex.FindStringSubmatch(first); m != nil {
+		directive := p.parseDirective(m[2], m[3], body)
+		return &SubstitutionDefinition{Name: m[1], Directive: directive}, next
+	}
+
+	if m := directiveRegex.FindStringSubmatch(first); m != nil {
+		return p.parseDirective(m[1], m[2], body), next
+	}
+
+	text := strings.TrimSpace(strings.Join(append([]string{first}, body...), "\n"))
+	return &Comment{Text: text}, next
+}
+
+// parseDirective splits a directive block into its argument, options and content.
+func (p *parser) parseDirective(name, argument string, body []string) *Directive {
+	d := &Directive{Name: name}
+	lower := strings.ToLower(name)
+
+	// Argument continuation lines run until the first option or blank line.
+	i := 0
+	args := []string{argument}
+	for i < len(body) && !isBlank(body[i]) && !optionRegex.MatchString(body[i]) {
+		args = append(args, body[i])
+		i++
+	}
+
+	// Options directly follow the argument.
+	for i < len(body) {
+		m := optionRegex.FindStringSubmatch(body[i])
+		if m == nil {
+			break
+		}
+		value := []string{m[2]}
+		i++
+		for i < len(body) && !isBlank(body[i]) && indentOf(body[i]) > 0 {
+			value = append(value, strings.TrimSpace(body[i]))
+			i++
+		}
+		d.Options = append(d.Options, Option{Name: m[1], Value: strings.TrimSpace(strings.Join(value, " "))})
+	}
+
+	content := trimBlankLines(body[i:])
+	if contentOnlyDirectives[lower] {
+		if args = trimBlankLines(args); len(args) > 0 && len(content) > 0 {
+			args = append(args, "")
+		}
+		content = append(args, content...)
+	} else {
+		d.Argument = strings.TrimSpace(strings.Join(trimBlankLines(args), "\n"))
+	}
+	d.Content = dedent(content)
+
+	if !rawDirectives[lower] && len(d.Content) > 0 {
+		d.Children = p.parseBody(d.Content, false)
+	}
+	return d
+}
@@ -0,0 +1,114 @@
+package rst
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var substitutionRefRegex = regexp.MustCompile(`\|([^|\s](?:[^|]*[^|\s])?)\|(__?)?`)
+
+// SubstitutionDefinitions returns the substitution definitions in src keyed
+// by normalized, lower-case name.
+func SubstitutionDefinitions(src string) map[string]*Directive {
+	defs := map[string]*Directive{}
+	Walk(Parse(src).Children, func(n Node) bool {
+		if def, ok := n.(*SubstitutionDefinition); ok {
+			defs[strings.ToLower(normalizeName(def.Name))] = def.Directive
+		}
+		return true
+	})
+	return defs
+}
+
+// Substitute replaces references to the replace, unicode and date
+// substitutions in defs with their text, outside literal text. References
+// that are also hyperlink references and other substitutions, such as
+// images, are left for the converter.
+func Substitute(src string, defs map[string]*Directive, now time.Time) string {
+	if len(defs) == 0 {
+		return src
+	}
+	return MapText(src, func(text string) string {
+		var b strings.Builder
+		last := 0
+		for _, loc := range substitutionRefRegex.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if loc[4] >= 0 || !isRefBoundary(text, start-1) || !isRefBoundary(text, end) || isDefinitionLine(text, start) {
+				continue
+			}
+			replacement, ok := substitutionText(defs[strings.ToLower(normalizeName(text[loc[2]:loc[3]]))], now)
+			if !ok {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(replacement)
+			last = end
+		}
+		b.WriteString(text[last:])
+		return b.String()
+	})
+}
+
+// isRefBoundary reports whether the byte at i may delimit inline markup.
+func isRefBoundary(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return true
+	}
+	return strings.IndexByte(" \t\n'\"([{<-/:.,;!?\\)]}>*_`", text[i]) >= 0
+}
+
+// isDefinitionLine reports whether the reference at i is the name of a
+// substitution definition.
+func isDefinitionLine(text string, i int) bool {
+	lineStart := strings.LastIndexByte(text[:i], '\n') + 1
+	return strings.TrimSpace(text[lineStart:i]) == ".."
+}
+
+// substitutionText returns the reStructuredText a substitution stands for.
+func substitutionText(d *Directive, now time.Time) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	switch strings.ToLower(d.Name) {
+	case "replace":
+		return strings.Join(strings.Fields(joinBlocks(d.Argument, strings.Join(d.Content, " "))), " "), true
+	case "unicode":
+		return unicodeText(d.Argument), true
+	case "date":
+		format := d.Argument
+		if format == "" {
+			format = "%Y-%m-%d"
+		}
+		return strftime(now, format), true
+	}
+	return "", false
+}
+
+// strftime formats t with the common Python strftime directives.
+func strftime(t time.Time, format string) string {
+	layouts := map[byte]string{
+		'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'H': "15", 'I': "03",
+		'M': "04", 'S': "05", 'p': "PM", 'B': "January", 'b': "Jan",
+		'A': "Monday", 'a': "Mon", 'Z': "MST", 'z': "-0700",
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; {
+		case c == '%':
+			b.WriteByte('%')
+		case c == 'j':
+			b.WriteString(t.Format("002"))
+		case layouts[c] != "":
+			b.WriteString(t.Format(layouts[c]))
+		default:
+			b.WriteString("%" + string(c))
+		}
+	}
+	return b.String()
+}
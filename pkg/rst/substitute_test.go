@@ -0,0 +1,69 @@
+package rst
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubstitute(t *testing.T) {
+	defs := SubstitutionDefinitions(`.. |product| replace:: *Widgets* Pro
+.. |copy| unicode:: U+000A9
+.. |today| date:: %d %B %Y
+.. |logo| image:: logo.png
+`)
+	now := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "replace",
+			input: "Buy |product| now.",
+			want:  "Buy *Widgets* Pro now.",
+		},
+		{
+			name:  "case-insensitive",
+			input: "Buy |Product|.",
+			want:  "Buy *Widgets* Pro.",
+		},
+		{
+			name:  "unicode and date",
+			input: "|copy| ACME, |today|",
+			want:  "© ACME, 05 March 2024",
+		},
+		{
+			name:  "image and unknown left alone",
+			input: "|logo| and |other|",
+			want:  "|logo| and |other|",
+		},
+		{
+			name:  "hyperlink reference left alone",
+			input: "See |product|_.",
+			want:  "See |product|_.",
+		},
+		{
+			name:  "literal text left alone",
+			input: "Write ``|product|``::\n\n   |product|\n\nDone |product|.",
+			want:  "Write ``|product|``::\n\n   |product|\n\nDone *Widgets* Pro.",
+		},
+		{
+			name:  "definition left alone",
+			input: ".. |product| replace:: Other\n\n|product|",
+			want:  ".. |product| replace:: Other\n\n*Widgets* Pro",
+		},
+		{
+			name:  "not a reference",
+			input: "a|product|b",
+			want:  "a|product|b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Substitute(tt.input, defs, now); got != tt.want {
+				t.Errorf("Substitute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
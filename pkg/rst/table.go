@@ -0,0 +1,319 @@
+package rst
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var simpleSpanRegex = regexp.MustCompile(`^-+( +-+)*$`)
+
+// parseGridTable parses a grid table starting at lines[i], following the
+// corner-scanning approach of docutils so that row and column spans are kept.
+func (p *parser) parseGridTable(lines []string, i int) (Node, int) {
+	end := i
+	for end < len(lines) && (strings.HasPrefix(lines[end], "+") || strings.HasPrefix(lines[end], "|")) {
+		end++
+	}
+
+	grid := newGrid(lines[i:end])
+	cells := grid.scan()
+	if cells == nil {
+		// Not a well-formed table: keep the text as a literal block.
+		return &LiteralBlock{Text: strings.Join(lines[i:end], "\n")}, end
+	}
+
+	rowSeps := map[int]bool{}
+	colSeps := map[int]bool{}
+	for _, c := range cells {
+		rowSeps[c.top], rowSeps[c.bottom] = true, true
+		colSeps[c.left], colSeps[c.right] = true, true
+	}
+	rows := sortedKeys(rowSeps)
+	cols := sortedKeys(colSeps)
+
+	table := &Table{}
+	for range rows[:len(rows)-1] {
+		table.Rows = append(table.Rows, &TableRow{})
+	}
+	for r, top := range rows {
+		if top > 0 && grid.isHeaderSeparator(top) {
+			table.HeaderRows = r
+		}
+	}
+
+	sort.Slice(cells, func(a, b int) bool {
+		if cells[a].top != cells[b].top {
+			return cells[a].top < cells[b].top
+		}
+		return cells[a].left < cells[b].left
+	})
+	for _, c := range cells {
+		row := indexOf(rows, c.top)
+		cell := &TableCell{
+			RowSpan:  indexOf(rows, c.bottom) - row,
+			ColSpan:  indexOf(cols, c.right) - indexOf(cols, c.left),
+			Children: p.parseBody(dedent(trimBlankLines(grid.content(c))), false),
+		}
+		table.Rows[row].Cells = append(table.Rows[row].Cells, cell)
+	}
+	return table, end
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func indexOf(values []int, v int) int {
+	for i, x := range values {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+type gridCell struct {
+	top, left, bottom, right int
+}
+
+type grid struct {
+	rows [][]rune
+}
+
+func newGrid(lines []string) *grid {
+	g := &grid{}
+	width := 0
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n > width {
+			width = n
+		}
+	}
+	for _, line := range lines {
+		row := []rune(line)
+		for len(row) < width {
+			row = append(row, ' ')
+		}
+		g.rows = append(g.rows, row)
+	}
+	return g
+}
+
+func (g *grid) at(row, col int) rune {
+	if row < 0 || row >= len(g.rows) || col < 0 || col >= len(g.rows[row]) {
+		return 0
+	}
+	return g.rows[row][col]
+}
+
+func (g *grid) isHeaderSeparator(row int) bool {
+	return strings.Contains(string(g.rows[row]), "=")
+}
+
+// scan returns every cell of the grid, or nil if the grid is malformed.
+func (g *grid) scan() []gridCell {
+	if len(g.rows) < 2 {
+		return nil
+	}
+	bottom, right := len(g.rows)-1, len(g.rows[0])-1
+	corners := [][2]int{{0, 0}}
+	seen := map[[2]int]bool{}
+	var cells []gridCell
+	for len(corners) > 0 {
+		corner := corners[0]
+		corners = corners[1:]
+		if seen[corner] {
+			continue
+		}
+		seen[corner] = true
+		top, left := corner[0], corner[1]
+		if top >= bottom || left >= right {
+			continue
+		}
+		c, ok := g.scanRight(top, left)
+		if !ok {
+			continue
+		}
+		cells = append(cells, c)
+		corners = append(corners, [2]int{top, c.right}, [2]int{c.bottom, left})
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+	return cells
+}
+
+func (g *grid) scanRight(top, left int) (gridCell, bool) {
+	for col := left + 1; col < len(g.rows[top]); col++ {
+		switch g.at(top, col) {
+		case '+':
+			if bottom, ok := g.scanDown(top, left, col); ok {
+				return gridCell{top: top, left: left, bottom: bottom, right: col}, true
+			}
+		case '-', '=':
+		default:
+			return gridCell{}, false
+		}
+	}
+	return gridCell{}, false
+}
+
+func (g *grid) scanDown(top, left, right int) (int, bool) {
+	for row := top + 1; row < len(g.rows); row++ {
+		switch g.at(row, right) {
+		case '+':
+			if g.scanLeft(top, left, row, right) {
+				return row, true
+			}
+		case '|':
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+func (g *grid) scanLeft(top, left, bottom, right int) bool {
+	for col := right - 1; col > left; col-- {
+		switch g.at(bottom, col) {
+		case '+', '-', '=':
+		default:
+			return false
+		}
+	}
+	if g.at(bottom, left) != '+' {
+		return false
+	}
+	for row := bottom - 1; row > top; row-- {
+		switch g.at(row, left) {
+		case '+', '|':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (g *grid) content(c gridCell) []string {
+	var lines []string
+	for row := c.top + 1; row < c.bottom; row++ {
+		lines = append(lines, strings.TrimRight(string(g.rows[row][c.left+1:c.right]), " "))
+	}
+	return lines
+}
+
+// parseSimpleTable parses a simple table starting at the border line lines[i].
+func (p *parser) parseSimpleTable(lines []string, i int) (Node, int) {
+	border := lines[i]
+	var columns [][2]int
+	for col := 0; col < len(border); {
+		if border[col] != '=' {
+			col++
+			continue
+		}
+		start := col
+		for col < len(border) && border[col] == '=' {
+			col++
+		}
+		columns = append(columns, [2]int{start, col})
+	}
+
+	// The table ends at a border followed by a blank line or the end of input.
+	end := i + 1
+	var borders []int
+	for end < len(lines) {
+		if simpleBorderRegex.MatchString(lines[end]) {
+			borders = append(borders, end)
+			if end+1 >= len(lines) || isBlank(lines[end+1]) {
+				end++
+				break
+			}
+		}
+		end++
+	}
+	if len(borders) == 0 {
+		return &Paragraph{Content: parseInline(border)}, i + 1
+	}
+
+	table := &Table{}
+	if len(borders) > 1 {
+		table.HeaderRows = -1
+	}
+	var current [][]string
+	flush := func() {
+		if current == nil {
+			return
+		}
+		row := &TableRow{}
+		for _, cell := range current {
+			row.Cells = append(row.Cells, &TableCell{
+				ColSpan:  1,
+				RowSpan:  1,
+				Children: p.parseBody(dedent(trimBlankLines(cell)), false),
+			})
+		}
+		table.Rows = append(table.Rows, row)
+		current = nil
+	}
+	for row := i + 1; row < end; row++ {
+		line := lines[row]
+		if simpleBorderRegex.MatchString(line) {
+			flush()
+			if table.HeaderRows == -1 {
+				table.HeaderRows = len(table.Rows)
+			}
+			continue
+		}
+		if isBlank(line) {
+			flush()
+			continue
+		}
+		if simpleSpanRegex.MatchString(line) {
+			// Column span underlines are not supported; the cells stay separate.
+			continue
+		}
+		texts := splitColumns(line, columns)
+		if current != nil && strings.TrimSpace(texts[0]) != "" {
+			flush()
+		}
+		if current == nil {
+			current = make([][]string, len(columns))
+		}
+		for c, text := range texts {
+			current[c] = append(current[c], text)
+		}
+	}
+	flush()
+	if table.HeaderRows < 0 {
+		table.HeaderRows = 0
+	}
+	return table, end
+}
+
+// splitColumns cuts line at the column boundaries; the last column takes the
+// remainder of the line.
+func splitColumns(line string, columns [][2]int) []string {
+	runes := []rune(line)
+	texts := make([]string, len(columns))
+	for c, col := range columns {
+		start := col[0]
+		stop := col[1]
+		if c == len(columns)-1 {
+			stop = len(runes)
+		}
+		if start >= len(runes) {
+			continue
+		}
+		if stop > len(runes) {
+			stop = len(runes)
+		}
+		texts[c] = strings.TrimRight(string(runes[start:stop]), " ")
+	}
+	return texts
+}
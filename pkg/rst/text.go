@@ -0,0 +1,115 @@
+package rst
+
+import (
+	"regexp"
+	"strings"
+)
+
+// literalDirectives have content that is shown verbatim, so source rewrites
+// must leave it alone.
+var literalDirectives = map[string]bool{
+	"code":            true,
+	"code-block":      true,
+	"sourcecode":      true,
+	"literalinclude":  true,
+	"math":            true,
+	"raw":             true,
+	"doctest":         true,
+	"testcode":        true,
+	"testoutput":      true,
+	"productionlist":  true,
+	"graphviz":        true,
+	"digraph":         true,
+	"graph":           true,
+	"uml":             true,
+	"mermaid":         true,
+	"jupyter-execute": true,
+	"ipython":         true,
+}
+
+var (
+	explicitLineRegex  = regexp.MustCompile(`^\.\.(?:\s+(.*))?$`)
+	inlineLiteralRegex = regexp.MustCompile("(?s)``.+?``")
+)
+
+// MapText applies fn to the parts of the reStructuredText source src that may
+// contain inline markup. Literal blocks, the content of code and other verbatim
+// directives, comments and inline literals are left untouched. Consecutive
+// lines are passed to fn together so that markup spanning lines is seen whole.
+func MapText(src string, fn func(text string) string) string {
+	lines := strings.Split(src, "\n")
+	literal := LiteralLines(lines)
+
+	var out []string
+	var chunk []string
+	flush := func() {
+		if chunk != nil {
+			out = append(out, mapInline(strings.Join(chunk, "\n"), fn))
+			chunk = nil
+		}
+	}
+	for i, line := range lines {
+		if literal[i] {
+			flush()
+			out = append(out, line)
+			continue
+		}
+		chunk = append(chunk, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+func mapInline(text string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range inlineLiteralRegex.FindAllStringIndex(text, -1) {
+		b.WriteString(fn(text[last:loc[0]]))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(fn(text[last:]))
+	return b.String()
+}
+
+// LiteralLines reports for every line whether it belongs to a literal block,
+// the body of a verbatim directive or a comment.
+func LiteralLines(lines []string) []bool {
+	literal := make([]bool, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := expandTabs(lines[i])
+		trimmed := strings.TrimSpace(line)
+		indent := indentOf(line)
+
+		startsBlock := false
+		if m := explicitLineRegex.FindStringSubmatch(trimmed); m != nil {
+			switch body := m[1]; {
+			case targetRegex.MatchString(body), footnoteRegex.MatchString(body), substitutionRegex.MatchString(body):
+			case directiveRegex.MatchString(body):
+				name := strings.ToLower(directiveRegex.FindStringSubmatch(body)[1])
+				startsBlock = literalDirectives[name]
+			default:
+				// A comment, including its first line.
+				literal[i] = true
+				startsBlock = true
+			}
+		} else if strings.HasSuffix(trimmed, "::") && (i+1 >= len(lines) || isBlank(lines[i+1])) {
+			startsBlock = true
+		}
+
+		if !startsBlock {
+			continue
+		}
+		j := i + 1
+		for j < len(lines) && (isBlank(lines[j]) || indentOf(expandTabs(lines[j])) > indent) {
+			literal[j] = true
+			j++
+		}
+		// Trailing blank lines are not part of the block.
+		for k := j - 1; k > i && isBlank(lines[k]); k-- {
+			literal[k] = false
+		}
+		i = j - 1
+	}
+	return literal
+}
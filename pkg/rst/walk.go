@@ -0,0 +1,87 @@
+package rst
+
+// Children returns the block-level children of n. List items, definitions,
+// fields and table cells are flattened into a single slice.
+func Children(n Node) []Node {
+	switch n := n.(type) {
+	case *Section:
+		return n.Children
+	case *BulletList:
+		return listChildren(n.Items)
+	case *EnumeratedList:
+		return listChildren(n.Items)
+	case *DefinitionList:
+		var children []Node
+		for _, item := range n.Items {
+			children = append(children, item.Definition...)
+		}
+		return children
+	case *FieldList:
+		var children []Node
+		for _, field := range n.Fields {
+			children = append(children, field.Body...)
+		}
+		return children
+	case *BlockQuote:
+		return n.Children
+	case *Table:
+		var children []Node
+		for _, row := range n.Rows {
+			for _, cell := range row.Cells {
+				children = append(children, cell.Children...)
+			}
+		}
+		return children
+	case *Directive:
+		return n.Children
+	case *Footnote:
+		return n.Children
+	}
+	return nil
+}
+
+func listChildren(items []*ListItem) []Node {
+	var children []Node
+	for _, item := range items {
+		children = append(children, item.Children...)
+	}
+	return children
+}
+
+// Walk calls fn for every node in nodes in document order, descending into
+// the children of each node for which fn returns true.
+func Walk(nodes []Node, fn func(Node) bool) {
+	for _, n := range nodes {
+		if fn(n) {
+			Walk(Children(n), fn)
+		}
+	}
+}
+
+// PlainText returns the text content of inlines with all markup removed.
+func PlainText(inlines []Inline) string {
+	var text string
+	for _, in := range inlines {
+		switch in := in.(type) {
+		case *Text:
+			text += in.Text
+		case *Emphasis:
+			text += PlainText(in.Children)
+		case *Strong:
+			text += PlainText(in.Children)
+		case *Literal:
+			text += in.Text
+		case *Reference:
+			text += in.Text
+		case *Role:
+			text += roleText(in.Text)
+		case *FootnoteReference:
+			text += "[" + in.Label + "]"
+		case *SubstitutionReference:
+			text += "|" + in.Name + "|"
+		case *InlineTarget:
+			text += in.Text
+		}
+	}
+	return text
+}
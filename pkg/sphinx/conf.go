@@ -0,0 +1,196 @@
+// Package sphinx reads the settings of a Sphinx project from its conf.py
+// without executing any Python.
+package sphinx
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConfFile is the name of the Sphinx configuration file.
+const ConfFile = "conf.py"
+
+// Conf holds the conf.py settings rst2md understands. Settings that are not
+// assigned, or not assigned a literal, are left empty.
+type Conf struct {
+	Project         string
+	Author          string
+	Version         string
+	Release         string
+	MasterDoc       string   // master_doc, or root_doc in Sphinx 4 and later
+	SourceSuffix    []string // Suffixes of reStructuredText sources, with the dot
+	ExcludePatterns []string
+	RstProlog       string
+	RstEpilog       string
+	HTMLStaticPath  []string
+	// HighlightLanguage is the default language of code and literal blocks
+	HighlightLanguage string
+	// Unsupported lists the settings above that are assigned expressions too
+	// complex to evaluate statically.
+	Unsupported []string
+}
+
+var assignmentRegex = regexp.MustCompile(`(?s)^([A-Za-z_]\w*)\s*(\+?=)\s*(.*)$`)
+
+// ReadConf reads the conf.py at path.
+func ReadConf(path string) (*Conf, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return ParseConf(string(data)), nil
+}
+
+// ParseConf extracts the settings from the source of a conf.py. Only
+// top-level assignments of literals, names assigned earlier and string or
+// list concatenations of those are evaluated.
+func ParseConf(src string) *Conf {
+	values := map[string]interface{}{}
+	var unsupported []string
+	for _, stmt := range statements(src) {
+		m := assignmentRegex.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		name, op, expr := m[1], m[2], m[3]
+		value, err := evaluate(expr, values)
+		if err != nil {
+			unsupported = append(unsupported, name)
+			delete(values, name)
+			continue
+		}
+		if op == "+=" {
+			value, err = add(values[name], value)
+			if err != nil {
+				unsupported = append(unsupported, name)
+				delete(values, name)
+				continue
+			}
+		}
+		values[name] = value
+	}
+
+	conf := &Conf{
+		Project:           stringValue(values["project"]),
+		Author:            stringValue(values["author"]),
+		Version:           stringValue(values["version"]),
+		Release:           stringValue(values["release"]),
+		MasterDoc:         stringValue(values["master_doc"]),
+		ExcludePatterns:   stringList(values["exclude_patterns"]),
+		RstProlog:         stringValue(values["rst_prolog"]),
+		RstEpilog:         stringValue(values["rst_epilog"]),
+		HTMLStaticPath:    stringList(values["html_static_path"]),
+		HighlightLanguage: stringValue(values["highlight_language"]),
+	}
+	if root := stringValue(values["root_doc"]); root != "" {
+		conf.MasterDoc = root
+	}
+	switch suffix := values["source_suffix"].(type) {
+	case string:
+		conf.SourceSuffix = []string{suffix}
+	case []interface{}:
+		conf.SourceSuffix = stringList(suffix)
+	case map[string]interface{}:
+		for ext, parser := range suffix {
+			if parser == "restructuredtext" {
+				conf.SourceSuffix = append(conf.SourceSuffix, ext)
+			}
+		}
+		sort.Strings(conf.SourceSuffix)
+	}
+
+	known := map[string]bool{
+		"project": true, "author": true, "version": true, "release": true,
+		"master_doc": true, "root_doc": true, "source_suffix": true,
+		"exclude_patterns": true, "rst_prolog": true, "rst_epilog": true,
+		"html_static_path": true, "highlight_language": true,
+	}
+	for _, name := range unsupported {
+		if known[name] {
+			conf.Unsupported = append(conf.Unsupported, name)
+		}
+	}
+	return conf
+}
+
+// statements splits Python source into top-level logical lines, joining
+// lines continued by open brackets, open triple-quoted strings or a trailing
+// backslash. Indented and compound statements are dropped.
+func statements(src string) []string {
+	var stmts []string
+	var current strings.Builder
+	depth := 0
+	var quote string // Delimiter of the open string, if any
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		if current.Len() == 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			continue
+		}
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case quote != "":
+				if c == '\\' {
+					i++
+				} else if strings.HasPrefix(line[i:], quote) {
+					i += len(quote) - 1
+					quote = ""
+				}
+			case c == '#':
+				line = line[:i]
+			case c == '"' || c == '\'':
+				quote = string(c)
+				if strings.HasPrefix(line[i:], strings.Repeat(quote, 3)) {
+					quote = strings.Repeat(quote, 3)
+					i += 2
+				}
+			case c == '(' || c == '[' || c == '{':
+				depth++
+			case c == ')' || c == ']' || c == '}':
+				depth--
+			}
+		}
+		// Single-quoted strings cannot span lines
+		if quote == "'" || quote == `"` {
+			quote = ""
+		}
+
+		continued := strings.HasSuffix(line, "\\") && quote == ""
+		if continued {
+			line = strings.TrimSuffix(line, "\\")
+		}
+		current.WriteString(line)
+		if depth > 0 || quote != "" || continued {
+			current.WriteString("\n")
+			continue
+		}
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+		depth = 0
+	}
+	return stmts
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func stringList(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	var list []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
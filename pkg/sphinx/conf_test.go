@@ -0,0 +1,87 @@
+package sphinx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConf(t *testing.T) {
+	src := `# Configuration file for the Sphinx documentation builder.
+import os
+import sys
+sys.path.insert(0, os.path.abspath('..'))
+
+project = 'Widgets'
+copyright = '2024, ACME'
+author = "ACME " \
+    "Docs"
+version = '1.2'
+release = version + '.3'
+master_doc = 'contents'
+
+extensions = [
+    'sphinx.ext.autodoc',  # API docs
+]
+
+source_suffix = {
+    '.rst': 'restructuredtext',
+    '.txt': 'restructuredtext',
+    '.md': 'markdown',
+}
+
+exclude_patterns = ['_build', 'Thumbs.db']
+exclude_patterns += ['drafts/**']
+
+rst_prolog = """
+.. |product| replace:: Widgets
+"""
+rst_epilog = r'\ Done'
+
+html_static_path = ('_static',)
+highlight_language = 'console'
+html_theme = get_theme()
+
+if os.environ.get('READTHEDOCS'):
+    project = 'Ignored'
+`
+	want := &Conf{
+		Project:           "Widgets",
+		Author:            "ACME Docs",
+		Version:           "1.2",
+		Release:           "1.2.3",
+		MasterDoc:         "contents",
+		SourceSuffix:      []string{".rst", ".txt"},
+		ExcludePatterns:   []string{"_build", "Thumbs.db", "drafts/**"},
+		RstProlog:         "\n.. |product| replace:: Widgets\n",
+		RstEpilog:         `\ Done`,
+		HTMLStaticPath:    []string{"_static"},
+		HighlightLanguage: "console",
+	}
+	if got := ParseConf(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseConf() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseConfUnsupported(t *testing.T) {
+	src := "project = os.environ['PROJECT']\nversion = f'{major}.0'\nroot_doc = 'index'\n"
+	got := ParseConf(src)
+	if got.Project != "" || got.Version != "" || got.MasterDoc != "index" {
+		t.Errorf("ParseConf() = %+v", got)
+	}
+	if want := []string{"project", "version"}; !reflect.DeepEqual(got.Unsupported, want) {
+		t.Errorf("Unsupported = %v, want %v", got.Unsupported, want)
+	}
+}
+
+func TestPrependProlog(t *testing.T) {
+	prolog := "\n.. |product| replace:: Widgets\n"
+	tests := map[string]string{
+		"Title\n=====\n":         ".. |product| replace:: Widgets\n\nTitle\n=====\n",
+		":author: Me\n\nTitle\n": ":author: Me\n\n.. |product| replace:: Widgets\n\n\nTitle\n",
+	}
+	for src, want := range tests {
+		if got := PrependProlog(src, prolog); got != want {
+			t.Errorf("PrependProlog(%q) = %q, want %q", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package sphinx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errUnsupported is returned for expressions that cannot be evaluated
+// without running Python.
+var errUnsupported = errors.New("unsupported expression")
+
+// evaluate evaluates a Python literal expression. Values are strings, bools,
+// float64 numbers, nil, []interface{} for lists and tuples, and
+// map[string]interface{} for dicts with string keys. Names refer to values.
+func evaluate(expr string, values map[string]interface{}) (interface{}, error) {
+	p := &exprParser{src: expr, values: values}
+	v, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, errUnsupported
+	}
+	return v, nil
+}
+
+type exprParser struct {
+	src    string
+	pos    int
+	values map[string]interface{}
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != '\\' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// sum parses operands joined by +.
+func (p *exprParser) sum() (interface{}, error) {
+	v, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '+' {
+		p.pos++
+		w, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if v, err = add(v, w); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// operand parses a literal or name; adjacent string literals are joined.
+func (p *exprParser) operand() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '[':
+		return p.sequence(']')
+	case c == '(':
+		return p.sequence(')')
+	case c == '{':
+		return p.dict()
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	case c == '\'' || c == '"' || unicode.IsLetter(rune(c)) || c == '_':
+		if s, ok, err := p.stringLiterals(); ok || err != nil {
+			return s, err
+		}
+		return p.name()
+	}
+	return nil, errUnsupported
+}
+
+// sequence parses a list or tuple, or a parenthesized expression.
+func (p *exprParser) sequence(end byte) (interface{}, error) {
+	p.pos++
+	items := []interface{}{}
+	trailingComma := false
+	for p.peek() != end {
+		if p.pos >= len(p.src) {
+			return nil, errUnsupported
+		}
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		trailingComma = false
+		if p.peek() == ',' {
+			p.pos++
+			trailingComma = true
+		} else if p.peek() != end {
+			return nil, errUnsupported
+		}
+	}
+	p.pos++
+	if end == ')' && len(items) == 1 && !trailingComma {
+		return items[0], nil
+	}
+	return items, nil
+}
+
+func (p *exprParser) dict() (interface{}, error) {
+	p.pos++
+	dict := map[string]interface{}{}
+	for p.peek() != '}' {
+		if p.pos >= len(p.src) {
+			return nil, errUnsupported
+		}
+		k, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok || p.peek() != ':' {
+			return nil, errUnsupported
+		}
+		p.pos++
+		v, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = v
+		if p.peek() == ',' {
+			p.pos++
+		} else if p.peek() != '}' {
+			return nil, errUnsupported
+		}
+	}
+	p.pos++
+	return dict, nil
+}
+
+func (p *exprParser) number() (interface{}, error) {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && strings.IndexByte("0123456789._eE", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(p.src[start:p.pos], "_", ""), 64)
+	if err != nil {
+		return nil, errUnsupported
+	}
+	return n, nil
+}
+
+// name parses a constant or a reference to a value assigned earlier.
+func (p *exprParser) name() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	// Calls, attributes and subscripts cannot be evaluated
+	if p.pos < len(p.src) && strings.IndexByte("(.[", p.src[p.pos]) >= 0 {
+		return nil, errUnsupported
+	}
+	switch name {
+	case "True":
+		return true, nil
+	case "False":
+		return false, nil
+	case "None":
+		return nil, nil
+	}
+	if v, ok := p.values[name]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: undefined name %s", errUnsupported, name)
+}
+
+// stringLiterals parses one or more adjacent string literals. It reports false
+// without consuming input if there is no string literal at the position.
+func (p *exprParser) stringLiterals() (string, bool, error) {
+	var b strings.Builder
+	found := false
+	for {
+		p.skipSpace()
+		s, ok, err := p.stringLiteral()
+		if err != nil {
+			return "", true, err
+		}
+		if !ok {
+			return b.String(), found, nil
+		}
+		found = true
+		b.WriteString(s)
+	}
+}
+
+func (p *exprParser) stringLiteral() (string, bool, error) {
+	i := p.pos
+	raw := false
+	for i < len(p.src) && i-p.pos < 2 && strings.IndexByte("rRuUbBfF", p.src[i]) >= 0 {
+		switch p.src[i] {
+		case 'r', 'R':
+			raw = true
+		case 'f', 'F':
+			// Formatted strings need evaluation
+			if i+1 < len(p.src) && (p.src[i+1] == '\'' || p.src[i+1] == '"') {
+				return "", false, errUnsupported
+			}
+		}
+		i++
+	}
+	if i >= len(p.src) || (p.src[i] != '\'' && p.src[i] != '"') {
+		return "", false, nil
+	}
+
+	quote := p.src[i : i+1]
+	if strings.HasPrefix(p.src[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	i += len(quote)
+
+	var b strings.Builder
+	for i < len(p.src) {
+		if strings.HasPrefix(p.src[i:], quote) {
+			p.pos = i + len(quote)
+			return b.String(), true, nil
+		}
+		c := p.src[i]
+		if c == '\\' && i+1 < len(p.src) {
+			if raw {
+				b.WriteString(p.src[i : i+2])
+			} else {
+				b.WriteString(unescape(p.src[i+1]))
+			}
+			i += 2
+			continue
+		}
+		if c == '\n' && len(quote) == 1 {
+			break
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return "", true, errUnsupported
+}
+
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case '\n':
+		return ""
+	case '\\', '\'', '"':
+		return string(c)
+	}
+	return "\\" + string(c)
+}
+
+// add implements + for strings and lists.
+func add(a, b interface{}) (interface{}, error) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return a + b, nil
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			return append(append([]interface{}{}, a...), b...), nil
+		}
+	}
+	return nil, errUnsupported
+}
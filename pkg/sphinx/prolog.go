@@ -0,0 +1,40 @@
+package sphinx
+
+import (
+	"regexp"
+	"strings"
+)
+
+var docinfoRegex = regexp.MustCompile(`^:\w+:.*?`)
+
+// PrependProlog inserts the rst_prolog text at the start of a document, after
+// any docinfo fields so that they remain the document's bibliographic fields,
+// as Sphinx does.
+func PrependProlog(src, prolog string) string {
+	if prolog == "" {
+		return src
+	}
+	lines := strings.Split(src, "\n")
+	pos := 0
+	for pos < len(lines) && docinfoRegex.MatchString(lines[pos]) {
+		pos++
+	}
+
+	var out []string
+	out = append(out, lines[:pos]...)
+	if pos > 0 {
+		out = append(out, "")
+	}
+	out = append(out, strings.Split(strings.Trim(prolog, "\n"), "\n")...)
+	out = append(out, "")
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, "\n")
+}
+
+// AppendEpilog adds the rst_epilog text at the end of a document.
+func AppendEpilog(src, epilog string) string {
+	if epilog == "" {
+		return src
+	}
+	return strings.TrimRight(src, "\n") + "\n\n" + strings.Trim(epilog, "\n") + "\n"
+}
@@ -7,6 +7,94 @@ type TOCItem struct {
 	Name           string
 	IsExternalLink bool
 	URL            string // Only used if IsExternalLink is true
+	IsSelf         bool   // Entry is the `self` keyword, a link back to the containing document
+	Group          string // Caption of the toctree the entry belongs to
+
+	// Options of the toctree the entry belongs to, which shape its menu entry
+	Hidden     bool // Left out of the menu
+	MaxDepth   int  // Menu levels below the entry, 1 for the entry alone; 0 if unset
+	TitlesOnly bool // Only documents, not their section pages, below the entry
+	Numbered   bool // Menu names numbered
+}
+
+// TocTree represents a toctree directive and its options.
+type TocTree struct {
+	Caption    string
+	MaxDepth   int
+	Hidden     bool
+	Glob       bool
+	TitlesOnly bool
+	Numbered   bool
+	Reversed   bool
+	Items      []TOCItem
+}
+
+// DocNode is a document in the site tree built by following toctrees from the
+// root document.
+type DocNode struct {
+	DocName   string // Source document name relative to the input directory, without suffix
+	Title     string
+	OutputDir string // Output directory relative to the output directory root
+	Weight    int    // Position among its siblings
+	Parent    *DocNode
+	Children  []*DocNode
+}
+
+// SiteTree is the hierarchy of documents reachable from the root document.
+type SiteTree struct {
+	Root  *DocNode
+	Nodes map[string]*DocNode // Keyed by DocName
+}
+
+// Label is a cross-reference target defined with `.. _label:`.
+type Label struct {
+	DocName string
+	Title   string // Section title, or the label itself for non-section targets
+	Section bool   // The label precedes a section title
+	Term    bool   // The label is a glossary term
+}
+
+// Page is a Markdown page written to the output directory.
+type Page struct {
+	Path    string   // Path relative to the output directory, slash-separated
+	URL     string   // Site-absolute URL of the page
+	DocName string   // Source document the page was generated from
+	Title   string   // Title from the front matter
+	Weight  int      // Weight from the front matter
+	Anchors []string // Heading anchors and explicit anchors in the content
+}
+
+// FrontMatter is the front matter of a generated page.
+type FrontMatter struct {
+	Title  string
+	Weight int                    // Position among its siblings, omitted if zero
+	Math   bool                   // The page holds math
+	Params map[string]interface{} // Further keys, such as the document's metadata
+}
+
+// PageInfo describes a generated page to the front matter rules.
+type PageInfo struct {
+	Title    string // Title of the page
+	DocTitle string // Title of its document, the Title of the document's _index.md
+	DocName  string // Source document, or the name a generated page is indexed under
+	Source   string // Source file relative to the input directory, slash-separated
+	Path     string // Page relative to the output directory, slash-separated
+	Section  int    // Index of the page among the pages of its document, 0 for _index.md
+	TOC      []int  // Position of the document in the TOC, from 1 at every level
+}
+
+// UnresolvedLink is a link in a generated page whose target could not be
+// found in the converted site.
+type UnresolvedLink struct {
+	Page   string // Path of the page relative to the output directory
+	Target string // Target as written in the source
+}
+
+// Problem is an issue found when validating a generated site.
+type Problem struct {
+	Kind   string // What is wrong, such as a broken link
+	Page   string // Path of the page or file relative to the output directory
+	Target string // The offending link, image or URL
 }
 
 // MenuItem represents a menu item in the site configuration.
@@ -15,11 +103,14 @@ type MenuItem struct {
 	Name       string `yaml:"name"`
 	URL        string `yaml:"url,omitempty"`
 	Weight     int    `yaml:"weight"`
+	Parent     string `yaml:"parent,omitempty"`
 }
 
 // SiteConfig represents the structure of the site's configuration file.
 type SiteConfig struct {
-	Menu struct {
+	Title  string            `yaml:"title,omitempty"`
+	Params map[string]string `yaml:"params,omitempty"`
+	Menu   struct {
 		Main []MenuItem `yaml:"main"`
 	} `yaml:"menu"`
 }
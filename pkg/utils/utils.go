@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/spandigital/presidium-rst-to-markdown/pkg/config"
 )
@@ -19,6 +20,19 @@ func GenerateSlug(input string) string {
 	return whiteSpaceReg.ReplaceAllString(strings.ToLower(strings.TrimSpace(input)), "_")
 }
 
+var anchorStripReg = regexp.MustCompile(`[^\p{L}\p{N}\s_-]`)
+
+// HeadingAnchor returns the anchor Hugo generates for a Markdown heading.
+func HeadingAnchor(title string) string {
+	anchor := anchorStripReg.ReplaceAllString(strings.ToLower(strings.TrimSpace(title)), "")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return '-'
+		}
+		return r
+	}, anchor)
+}
+
 // IsDirEmpty checks if a directory is empty.
 func IsDirEmpty(dir string) (bool, error) {
 	f, err := os.Open(dir)